@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "payments" ADD COLUMN "user_id" char(27) REFERENCES users(id);
+ALTER TABLE "payments" ADD COLUMN "payer_mobile" varchar(20);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "payer_mobile";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "user_id";
+-- +goose StatementEnd
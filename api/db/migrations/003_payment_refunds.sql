@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "payments" ADD COLUMN "refunded_at" timestamptz;
+CREATE INDEX "payments_user_id_idx" ON "payments" ("user_id", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "payments_user_id_idx";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "refunded_at";
+-- +goose StatementEnd
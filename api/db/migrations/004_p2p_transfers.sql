@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "tier" varchar(16) NOT NULL DEFAULT 'basic';
+
+CREATE TABLE "wallets" (
+    "user_id" char(27) PRIMARY KEY REFERENCES users(id),
+    "balance" varchar(32) NOT NULL,
+    "currency" char(3) NOT NULL,
+    "updated_at" timestamptz DEFAULT now()
+);
+
+CREATE TABLE "transfers" (
+    "id" char(27) PRIMARY KEY,
+    "sender_id" char(27) NOT NULL REFERENCES users(id),
+    "recipient_id" char(27) NOT NULL REFERENCES users(id),
+    "amount" varchar(32) NOT NULL,
+    "fee" varchar(32) NOT NULL,
+    "currency" char(3) NOT NULL,
+    "status" varchar(16) NOT NULL,
+    "note" varchar(255),
+    "created_at" timestamptz DEFAULT now()
+);
+
+CREATE INDEX "transfers_sender_id_idx" ON "transfers" ("sender_id", "created_at");
+CREATE INDEX "transfers_recipient_id_idx" ON "transfers" ("recipient_id", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "transfers";
+DROP TABLE IF EXISTS "wallets";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "tier";
+-- +goose StatementEnd
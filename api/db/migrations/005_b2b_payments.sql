@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "b2b_transfers" ADD COLUMN "failure_reason" text;
+CREATE INDEX "b2b_transfers_business_id_idx" ON "b2b_transfers" ("business_id", "created_at");
+CREATE INDEX "b2b_transfers_counterparty_idx" ON "b2b_transfers" ("counterparty", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "b2b_transfers_counterparty_idx";
+DROP INDEX IF EXISTS "b2b_transfers_business_id_idx";
+ALTER TABLE "b2b_transfers" DROP COLUMN IF EXISTS "failure_reason";
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "payments" ADD COLUMN "business_id" char(27) REFERENCES business(id);
+ALTER TABLE "payments" ADD COLUMN "channel" varchar(16) NOT NULL DEFAULT 'checkout';
+
+UPDATE "payments" p
+SET    business_id = s.business_id
+FROM   checkout_sessions s
+WHERE  s.id = p.session_id;
+
+ALTER TABLE "payments" ALTER COLUMN "business_id" SET NOT NULL;
+ALTER TABLE "payments" ALTER COLUMN "session_id" DROP NOT NULL;
+CREATE INDEX "payments_business_id_idx" ON "payments" ("business_id", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "payments" WHERE "session_id" IS NULL;
+DROP INDEX IF EXISTS "payments_business_id_idx";
+ALTER TABLE "payments" ALTER COLUMN "session_id" SET NOT NULL;
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "channel";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "business_id";
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "checkout_sessions" ADD COLUMN "env" varchar(16) NOT NULL DEFAULT 'prod';
+ALTER TABLE "payments" ADD COLUMN "env" varchar(16) NOT NULL DEFAULT 'prod';
+ALTER TABLE "webhooks" ADD COLUMN "env" varchar(16) NOT NULL DEFAULT 'prod';
+ALTER TABLE "b2b_transfers" ADD COLUMN "env" varchar(16) NOT NULL DEFAULT 'prod';
+
+ALTER TABLE "balances" ADD COLUMN "env" varchar(16) NOT NULL DEFAULT 'prod';
+ALTER TABLE "balances" DROP CONSTRAINT "balances_pkey";
+ALTER TABLE "balances" ADD PRIMARY KEY ("business_id", "env");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "balances" WHERE "env" <> 'prod';
+ALTER TABLE "balances" DROP CONSTRAINT "balances_pkey";
+ALTER TABLE "balances" ADD PRIMARY KEY ("business_id");
+ALTER TABLE "balances" DROP COLUMN IF EXISTS "env";
+ALTER TABLE "b2b_transfers" DROP COLUMN IF EXISTS "env";
+ALTER TABLE "webhooks" DROP COLUMN IF EXISTS "env";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "env";
+ALTER TABLE "checkout_sessions" DROP COLUMN IF EXISTS "env";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys minted before this migration have no salt and are still verified
+-- with the legacy argon2 hash.
+ALTER TABLE "api_keys" ADD COLUMN "key_salt" text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "key_salt";
+-- +goose StatementEnd
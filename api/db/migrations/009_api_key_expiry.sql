@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "api_keys" ADD COLUMN "expires_at" timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "expires_at";
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "api_keys" ADD COLUMN "last_used_at" timestamptz;
+ALTER TABLE "api_keys" ADD COLUMN "last_used_ip" varchar(45);
+
+CREATE TABLE "api_key_usage" (
+    "key_id" char(27) NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    "day" date NOT NULL,
+    "requests" integer NOT NULL DEFAULT 0,
+    PRIMARY KEY ("key_id", "day")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "api_key_usage";
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "last_used_ip";
+ALTER TABLE "api_keys" DROP COLUMN IF EXISTS "last_used_at";
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "audit_log" (
+    "id" char(27) PRIMARY KEY,
+    "user_id" char(27) NOT NULL REFERENCES users(id),
+    "event" varchar(64) NOT NULL,
+    "ip" varchar(45),
+    "details" jsonb,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "audit_log_user_id_idx" ON "audit_log" ("user_id", "created_at");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "audit_log";
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "business_members" (
+    "business_id" char(27) NOT NULL REFERENCES business(id),
+    "user_id" char(27) NOT NULL REFERENCES users(id),
+    "role" varchar(16) NOT NULL CHECK ("role" IN ('owner', 'admin', 'developer', 'viewer')),
+    "created_at" timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("business_id", "user_id")
+);
+
+CREATE INDEX "business_members_user_id_idx" ON "business_members" ("user_id");
+
+INSERT INTO "business_members" ("business_id", "user_id", "role")
+SELECT "id", "owner_id", 'owner' FROM "business";
+
+CREATE TABLE "business_invitations" (
+    "id" char(27) PRIMARY KEY,
+    "business_id" char(27) NOT NULL REFERENCES business(id),
+    "phone" varchar(20) NOT NULL,
+    "role" varchar(16) NOT NULL CHECK ("role" IN ('admin', 'developer', 'viewer')),
+    "invited_by" char(27) NOT NULL REFERENCES users(id),
+    "status" varchar(16) NOT NULL DEFAULT 'pending',
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "business_invitations_phone_idx" ON "business_invitations" ("phone", "status");
+CREATE INDEX "business_invitations_business_id_idx" ON "business_invitations" ("business_id", "status");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS "business_invitations";
+DROP TABLE IF EXISTS "business_members";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "business" DROP CONSTRAINT IF EXISTS "business_owner_id_key";
+CREATE INDEX "business_owner_id_idx" ON "business" ("owner_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS "business_owner_id_idx";
+ALTER TABLE "business" ADD CONSTRAINT "business_owner_id_key" UNIQUE ("owner_id");
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "business" ADD COLUMN "logo_url" text;
+ALTER TABLE "business" ADD COLUMN "support_email" varchar(255);
+ALTER TABLE "business" ADD COLUMN "support_phone" varchar(32);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "business" DROP COLUMN IF EXISTS "support_phone";
+ALTER TABLE "business" DROP COLUMN IF EXISTS "support_email";
+ALTER TABLE "business" DROP COLUMN IF EXISTS "logo_url";
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Amounts are stored in the minor unit of their currency: XOF, UGX and the
+-- other zero-decimal currencies are whole units, the rest are hundredths.
+CREATE FUNCTION minor_unit_factor(currency char(3)) RETURNS numeric AS $$
+    SELECT CASE WHEN currency IN ('XOF', 'XAF', 'UGX', 'GNF', 'RWF', 'JPY', 'KRW') THEN 1 ELSE 100 END::numeric
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE "checkout_sessions" ALTER COLUMN "amount" TYPE bigint USING round("amount"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "payments" ALTER COLUMN "amount" TYPE bigint USING round("amount"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "b2b_transfers" ALTER COLUMN "amount" TYPE bigint USING round("amount"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "balances" ALTER COLUMN "available" TYPE bigint USING round("available"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "balances" ALTER COLUMN "pending" TYPE bigint USING round("pending"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "wallets" ALTER COLUMN "balance" TYPE bigint USING round("balance"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "transfers" ALTER COLUMN "amount" TYPE bigint USING round("amount"::numeric * minor_unit_factor("currency"))::bigint;
+ALTER TABLE "transfers" ALTER COLUMN "fee" TYPE bigint USING round("fee"::numeric * minor_unit_factor("currency"))::bigint;
+
+-- Rows written before amounts were validated are left unchecked.
+ALTER TABLE "checkout_sessions" ADD CONSTRAINT "checkout_sessions_amount_check" CHECK ("amount" > 0) NOT VALID;
+ALTER TABLE "payments" ADD CONSTRAINT "payments_amount_check" CHECK ("amount" > 0) NOT VALID;
+ALTER TABLE "b2b_transfers" ADD CONSTRAINT "b2b_transfers_amount_check" CHECK ("amount" > 0) NOT VALID;
+ALTER TABLE "transfers" ADD CONSTRAINT "transfers_amount_check" CHECK ("amount" > 0 AND "fee" >= 0) NOT VALID;
+ALTER TABLE "balances" ADD CONSTRAINT "balances_amounts_check" CHECK ("available" >= 0 AND "pending" >= 0) NOT VALID;
+ALTER TABLE "wallets" ADD CONSTRAINT "wallets_balance_check" CHECK ("balance" >= 0) NOT VALID;
+
+DROP FUNCTION minor_unit_factor(char(3));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Amounts are stored in the minor unit of their currency: XOF, UGX and the
+-- other zero-decimal currencies are whole units, the rest are hundredths.
+CREATE FUNCTION minor_unit_factor(currency char(3)) RETURNS numeric AS $$
+    SELECT CASE WHEN currency IN ('XOF', 'XAF', 'UGX', 'GNF', 'RWF', 'JPY', 'KRW') THEN 1 ELSE 100 END::numeric
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE "wallets" DROP CONSTRAINT IF EXISTS "wallets_balance_check";
+ALTER TABLE "balances" DROP CONSTRAINT IF EXISTS "balances_amounts_check";
+ALTER TABLE "transfers" DROP CONSTRAINT IF EXISTS "transfers_amount_check";
+ALTER TABLE "b2b_transfers" DROP CONSTRAINT IF EXISTS "b2b_transfers_amount_check";
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_amount_check";
+ALTER TABLE "checkout_sessions" DROP CONSTRAINT IF EXISTS "checkout_sessions_amount_check";
+
+ALTER TABLE "checkout_sessions" ALTER COLUMN "amount" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "amount"::text ELSE round("amount"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "payments" ALTER COLUMN "amount" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "amount"::text ELSE round("amount"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "b2b_transfers" ALTER COLUMN "amount" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "amount"::text ELSE round("amount"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "balances" ALTER COLUMN "available" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "available"::text ELSE round("available"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "balances" ALTER COLUMN "pending" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "pending"::text ELSE round("pending"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "wallets" ALTER COLUMN "balance" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "balance"::text ELSE round("balance"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "transfers" ALTER COLUMN "amount" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "amount"::text ELSE round("amount"::numeric / minor_unit_factor("currency"), 2)::text END);
+ALTER TABLE "transfers" ALTER COLUMN "fee" TYPE varchar(32) USING (CASE WHEN minor_unit_factor("currency") = 1 THEN "fee"::text ELSE round("fee"::numeric / minor_unit_factor("currency"), 2)::text END);
+
+DROP FUNCTION minor_unit_factor(char(3));
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A business without its own fee schedule pays the checkout fee of its
+-- country.
+ALTER TABLE "business" ADD COLUMN "fee_basis_points" integer;
+ALTER TABLE "business" ADD COLUMN "fee_min" bigint;
+ALTER TABLE "business" ADD COLUMN "fee_max" bigint;
+ALTER TABLE "business" ADD CONSTRAINT "business_fee_check" CHECK ("fee_basis_points" >= 0 AND "fee_min" >= 0 AND "fee_max" >= 0);
+
+-- amount is the gross paid by the customer, net_amount what the business is
+-- credited once the fee is withheld. Failed payments credit nothing, and
+-- earlier succeeded payments were credited in full.
+ALTER TABLE "payments" ADD COLUMN "fee" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "payments" ADD COLUMN "net_amount" bigint NOT NULL DEFAULT 0;
+UPDATE "payments" SET "net_amount" = "amount" WHERE "status" = 'succeeded';
+ALTER TABLE "payments" ADD CONSTRAINT "payments_fee_check" CHECK ("fee" >= 0 AND "net_amount" >= 0 AND "fee" + "net_amount" <= "amount");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_fee_check";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "net_amount";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "fee";
+ALTER TABLE "business" DROP CONSTRAINT IF EXISTS "business_fee_check";
+ALTER TABLE "business" DROP COLUMN IF EXISTS "fee_max";
+ALTER TABLE "business" DROP COLUMN IF EXISTS "fee_min";
+ALTER TABLE "business" DROP COLUMN IF EXISTS "fee_basis_points";
+-- +goose StatementEnd
@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "settlements" (
+    "id" char(27) PRIMARY KEY,
+    "business_id" char(27) NOT NULL REFERENCES business(id),
+    "env" varchar(16) NOT NULL,
+    "amount" bigint NOT NULL,
+    "currency" char(3) NOT NULL,
+    "payment_count" integer NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX "settlements_business_id_idx" ON "settlements" ("business_id", "env", "created_at");
+
+-- Succeeded payments are credited to the pending balance and move to the
+-- available one when settled, after settles_at. Earlier payments were
+-- credited as available and count as settled; the oldest have no
+-- completed_at.
+ALTER TABLE "payments" ADD COLUMN "settles_at" timestamptz;
+ALTER TABLE "payments" ADD COLUMN "settlement_id" char(27) REFERENCES settlements(id) DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE "payments" ADD COLUMN "settled_at" timestamptz;
+UPDATE "payments"
+SET    "settles_at" = COALESCE("completed_at", "created_at", now()),
+       "settled_at" = COALESCE("completed_at", "created_at", now())
+WHERE  "status" = 'succeeded';
+
+CREATE INDEX "payments_unsettled_idx" ON "payments" ("settles_at") WHERE "status" = 'succeeded' AND "settled_at" IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Funds still pending are made available, as they were before settlement.
+UPDATE "balances" SET "available" = "available" + "pending", "pending" = 0;
+DROP INDEX IF EXISTS "payments_unsettled_idx";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "settled_at";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "settlement_id";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "settles_at";
+DROP TABLE IF EXISTS "settlements";
+-- +goose StatementEnd
@@ -1,6 +1,6 @@
 -- name: CreateAPIKey :one
-INSERT INTO api_keys (id, business_id, prefix, key_hash, scopes, env)
-VALUES ($1, $2, $3, $4, $5, $6)
+INSERT INTO api_keys (id, business_id, prefix, key_hash, scopes, env, key_salt, expires_at, allowed_cidrs)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 RETURNING *;
 
 -- name: GetAPIKeyByID :one
@@ -8,8 +8,14 @@ SELECT *
 FROM api_keys
 WHERE id = $1;
 
+-- name: GetAPIKeyForAuth :one
+SELECT k.*, b.name AS business_name
+FROM api_keys k
+JOIN business b ON k.business_id = b.id
+WHERE k.id = $1;
+
 -- name: ListAPIKeys :many
-SELECT id, business_id, prefix, scopes, env, status, created_at
+SELECT id, business_id, prefix, scopes, env, status, created_at, expires_at, last_used_at, last_used_ip, allowed_cidrs
 FROM api_keys
 WHERE business_id = $1 AND status = 'active'
 ORDER BY created_at DESC;
@@ -17,4 +23,27 @@ ORDER BY created_at DESC;
 -- name: RevokeAPIKey :exec
 UPDATE api_keys
 SET status = 'revoked'
-WHERE id = $1 AND business_id = $2;
\ No newline at end of file
+WHERE id = $1 AND business_id = $2;
+
+-- name: ExpireAPIKey :exec
+UPDATE api_keys
+SET expires_at = $3
+WHERE id = $1 AND business_id = $2;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $2, last_used_ip = $3
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2);
+
+-- name: AddAPIKeyUsage :exec
+INSERT INTO api_key_usage (key_id, day, requests)
+VALUES ($1, $2, $3)
+ON CONFLICT (key_id, day) DO UPDATE
+SET requests = api_key_usage.requests + EXCLUDED.requests;
+
+-- name: ListAPIKeyUsage :many
+SELECT u.key_id, u.day, u.requests
+FROM api_key_usage u
+JOIN api_keys k ON k.id = u.key_id
+WHERE k.business_id = $1 AND u.day >= $2
+ORDER BY u.day DESC;
@@ -0,0 +1,3 @@
+-- name: CreateAuditLog :exec
+INSERT INTO audit_log (id, user_id, event, ip, details)
+VALUES ($1, $2, $3, $4, $5);
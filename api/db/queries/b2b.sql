@@ -0,0 +1,33 @@
+-- name: CreateB2BTransfer :one
+INSERT INTO b2b_transfers (
+    id,
+    business_id,
+    counterparty,
+    amount,
+    currency,
+    status,
+    reference,
+    failure_reason,
+    env
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING *;
+
+-- name: GetB2BTransfer :one
+SELECT t.*, s.name AS sender_name, c.name AS counterparty_name
+FROM b2b_transfers t
+JOIN business s ON s.id = t.business_id
+JOIN business c ON c.id = t.counterparty
+WHERE t.id = sqlc.arg(id)
+  AND t.env = sqlc.arg(env)
+  AND (t.business_id = sqlc.arg(business_id) OR t.counterparty = sqlc.arg(business_id));
+
+-- name: ListB2BTransfers :many
+SELECT t.*, s.name AS sender_name, c.name AS counterparty_name
+FROM b2b_transfers t
+JOIN business s ON s.id = t.business_id
+JOIN business c ON c.id = t.counterparty
+WHERE (t.business_id = $1 OR t.counterparty = $1)
+  AND t.env = $2
+ORDER BY t.created_at DESC
+LIMIT $3 OFFSET $4;
@@ -0,0 +1,49 @@
+-- name: GetBalance :one
+SELECT * FROM balances
+WHERE business_id = $1 AND env = $2;
+
+-- name: CreditBalance :one
+INSERT INTO balances (business_id, available, pending, currency, env)
+VALUES ($1, $2, '0', $3, $4)
+ON CONFLICT (business_id, env) DO UPDATE
+SET available = balances.available + EXCLUDED.available
+RETURNING *;
+
+-- name: CreditPendingBalance :one
+INSERT INTO balances (business_id, available, pending, currency, env)
+VALUES ($1, 0, $2, $3, $4)
+ON CONFLICT (business_id, env) DO UPDATE
+SET pending = balances.pending + EXCLUDED.pending
+RETURNING *;
+
+-- name: DebitBalance :one
+UPDATE balances
+SET    available = available - sqlc.arg(amount)::bigint
+WHERE  business_id = sqlc.arg(business_id)
+  AND  env = sqlc.arg(env)
+  AND  available >= sqlc.arg(amount)::bigint
+RETURNING *;
+
+-- name: DebitPendingBalance :one
+UPDATE balances
+SET    pending = pending - sqlc.arg(amount)::bigint
+WHERE  business_id = sqlc.arg(business_id)
+  AND  env = sqlc.arg(env)
+  AND  pending >= sqlc.arg(amount)::bigint
+RETURNING *;
+
+-- name: SettleBalance :one
+UPDATE balances
+SET    pending = pending - sqlc.arg(amount)::bigint,
+       available = available + sqlc.arg(amount)::bigint
+WHERE  business_id = sqlc.arg(business_id)
+  AND  env = sqlc.arg(env)
+  AND  pending >= sqlc.arg(amount)::bigint
+RETURNING *;
+
+-- name: BusinessHasBalance :one
+SELECT EXISTS (
+    SELECT 1 FROM balances
+    WHERE business_id = $1
+      AND (available <> 0 OR pending <> 0)
+);
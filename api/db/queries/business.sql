@@ -3,12 +3,35 @@ INSERT INTO business (id, name, owner_id, country, currency)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
--- name: GetBusinessByOwnerID :one
-SELECT *
-FROM business
-WHERE owner_id = $1;
-
 -- name: GetBusinessByID :one
 SELECT *
 FROM business
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListBusinessesForUser :many
+SELECT b.id, b.owner_id, b.name, b.country, b.currency, b.created_at, m.role
+FROM business b
+JOIN business_members m ON m.business_id = b.id
+WHERE m.user_id = $1
+ORDER BY m.created_at;
+
+-- name: UpdateBusinessProfile :one
+UPDATE business
+SET
+    name = $2,
+    logo_url = $3,
+    country = $4,
+    currency = $5,
+    support_email = $6,
+    support_phone = $7
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateBusinessFees :one
+UPDATE business
+SET
+    fee_basis_points = $2,
+    fee_min = $3,
+    fee_max = $4
+WHERE id = $1
+RETURNING *;
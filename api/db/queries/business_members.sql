@@ -0,0 +1,50 @@
+-- name: CreateBusinessMember :exec
+INSERT INTO business_members (business_id, user_id, role)
+VALUES ($1, $2, $3);
+
+-- name: GetBusinessMember :one
+SELECT *
+FROM business_members
+WHERE business_id = $1 AND user_id = $2;
+
+-- name: ListBusinessMembers :many
+SELECT m.business_id, m.user_id, m.role, m.created_at, u.phone
+FROM business_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.business_id = $1
+ORDER BY m.created_at;
+
+-- name: UpdateBusinessMemberRole :exec
+UPDATE business_members SET role = $3
+WHERE business_id = $1 AND user_id = $2;
+
+-- name: DeleteBusinessMember :exec
+DELETE FROM business_members
+WHERE business_id = $1 AND user_id = $2;
+
+-- name: CreateBusinessInvitation :one
+INSERT INTO business_invitations (id, business_id, phone, role, invited_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetBusinessInvitation :one
+SELECT *
+FROM business_invitations
+WHERE id = $1;
+
+-- name: ListBusinessInvitations :many
+SELECT *
+FROM business_invitations
+WHERE business_id = $1 AND status = 'pending'
+ORDER BY created_at DESC;
+
+-- name: ListInvitationsForPhone :many
+SELECT i.id, i.business_id, i.phone, i.role, i.invited_by, i.status, i.created_at, b.name AS business_name
+FROM business_invitations i
+JOIN business b ON b.id = i.business_id
+WHERE i.phone = $1 AND i.status = 'pending'
+ORDER BY i.created_at DESC;
+
+-- name: UpdateBusinessInvitationStatus :exec
+UPDATE business_invitations SET status = $2
+WHERE id = $1;
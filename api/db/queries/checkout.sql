@@ -13,14 +13,15 @@ INSERT INTO checkout_sessions (
     wave_launch_url,
     transaction_id,
     payment_status,
-    expires_at
+    expires_at,
+    env
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
 ) RETURNING *;
 
 -- name: GetCheckoutSession :one
 SELECT * FROM checkout_sessions
-WHERE id = $1 AND business_id = $2;
+WHERE id = $1 AND business_id = $2 AND env = $3;
 
 -- name: GetCheckoutSessionByID :one
 SELECT * FROM checkout_sessions
@@ -28,13 +29,14 @@ WHERE id = $1;
 
 -- name: GetCheckoutSessionByTxID :one
 SELECT * FROM checkout_sessions
-WHERE transaction_id = $1 AND business_id = $2
+WHERE transaction_id = $1 AND business_id = $2 AND env = $3
 LIMIT 1;
 
 -- name: SearchCheckoutSessions :many
 SELECT * FROM checkout_sessions
 WHERE business_id = $1
   AND client_reference = $2
+  AND env = $3
 ORDER BY when_created DESC;
 
 -- name: UpdateCheckoutPaymentStatus :exec
@@ -55,6 +57,7 @@ SET    status = 'complete',
        payment_status = 'succeeded',
        when_completed = now()
 WHERE  id = $1
+  AND  status = 'open'
 RETURNING *;
 
 -- name: FailCheckoutSession :one
@@ -62,8 +65,16 @@ UPDATE checkout_sessions
 SET    payment_status = 'cancelled',
        last_payment_error = $2
 WHERE  id = $1
+  AND  status = 'open'
 RETURNING *;
 
+-- name: BusinessHasOpenCheckoutSessions :one
+SELECT EXISTS (
+    SELECT 1 FROM checkout_sessions
+    WHERE business_id = $1
+      AND status = 'open'
+);
+
 -- name: CreatePayment :one
 INSERT INTO payments (
     id,
@@ -71,11 +82,36 @@ INSERT INTO payments (
     amount,
     currency,
     status,
-    failure_reason
+    failure_reason,
+    user_id,
+    payer_mobile,
+    business_id,
+    channel,
+    env,
+    fee,
+    net_amount,
+    settles_at,
+    completed_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, now()
 ) RETURNING *;
 
+-- name: RefundSessionPayment :one
+UPDATE payments
+SET    refunded_at = now()
+WHERE  session_id = $1
+  AND  status = 'succeeded'
+  AND  refunded_at IS NULL
+RETURNING amount, net_amount, settled_at, user_id;
+
+-- name: ListBusinessPayments :many
+SELECT * FROM payments
+WHERE business_id = $1
+  AND env = $2
+  AND status = 'succeeded'
+ORDER BY created_at DESC, id DESC
+LIMIT $3 OFFSET $4;
+
 -- name: GetAPIKeyByPrefixAndSecret :one
 SELECT k.*, b.id as business_id_alias, b.name as business_name
 FROM api_keys k
@@ -0,0 +1,24 @@
+-- name: ListBusinessesToSettle :many
+SELECT DISTINCT business_id, env
+FROM payments
+WHERE status = 'succeeded'
+  AND settled_at IS NULL
+  AND refunded_at IS NULL
+  AND settles_at <= $1;
+
+-- name: SettlePayments :many
+UPDATE payments
+SET    settlement_id = sqlc.arg(settlement_id),
+       settled_at = now()
+WHERE  business_id = sqlc.arg(business_id)
+  AND  env = sqlc.arg(env)
+  AND  status = 'succeeded'
+  AND  settled_at IS NULL
+  AND  refunded_at IS NULL
+  AND  settles_at <= sqlc.arg(cutoff)
+RETURNING id, net_amount, currency;
+
+-- name: CreateSettlement :one
+INSERT INTO settlements (id, business_id, env, amount, currency, payment_count)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
@@ -0,0 +1,62 @@
+-- name: ListUserTransactions :many
+SELECT t.id, t.kind, t.business_name, t.counterparty_mobile, t.amount, t.fee, t.currency, t.status, t.when_created, t.when_completed
+FROM (
+    SELECT p.id,
+           'payment'::text AS kind,
+           b.name AS business_name,
+           ''::text AS counterparty_mobile,
+           p.amount,
+           0::bigint AS fee,
+           p.currency,
+           p.status,
+           p.created_at AS when_created,
+           p.completed_at AS when_completed
+    FROM payments p
+    JOIN business b ON b.id = p.business_id
+    WHERE p.user_id = $1
+    UNION ALL
+    SELECT 'refund_' || p.id AS id,
+           'refund'::text AS kind,
+           b.name AS business_name,
+           ''::text AS counterparty_mobile,
+           p.amount,
+           0::bigint AS fee,
+           p.currency,
+           'succeeded' AS status,
+           p.refunded_at AS when_created,
+           p.refunded_at AS when_completed
+    FROM payments p
+    JOIN business b ON b.id = p.business_id
+    WHERE p.user_id = $1
+      AND p.refunded_at IS NOT NULL
+    UNION ALL
+    SELECT tr.id,
+           'transfer_sent'::text AS kind,
+           ''::text AS business_name,
+           u.phone AS counterparty_mobile,
+           tr.amount,
+           tr.fee,
+           tr.currency,
+           tr.status,
+           tr.created_at AS when_created,
+           tr.created_at AS when_completed
+    FROM transfers tr
+    JOIN users u ON u.id = tr.recipient_id
+    WHERE tr.sender_id = $1
+    UNION ALL
+    SELECT tr.id,
+           'transfer_received'::text AS kind,
+           ''::text AS business_name,
+           u.phone AS counterparty_mobile,
+           tr.amount,
+           0::bigint AS fee,
+           tr.currency,
+           tr.status,
+           tr.created_at AS when_created,
+           tr.created_at AS when_completed
+    FROM transfers tr
+    JOIN users u ON u.id = tr.sender_id
+    WHERE tr.recipient_id = $1
+) t
+ORDER BY t.when_created DESC, t.id DESC
+LIMIT $2 OFFSET $3;
@@ -0,0 +1,28 @@
+-- name: CreateTransfer :one
+INSERT INTO transfers (
+    id,
+    sender_id,
+    recipient_id,
+    amount,
+    fee,
+    currency,
+    status,
+    note
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetTransferForUser :one
+SELECT t.*, s.phone AS sender_mobile, r.phone AS recipient_mobile
+FROM transfers t
+JOIN users s ON s.id = t.sender_id
+JOIN users r ON r.id = t.recipient_id
+WHERE t.id = sqlc.arg(id)
+  AND (t.sender_id = sqlc.arg(user_id) OR t.recipient_id = sqlc.arg(user_id));
+
+-- name: SumTransfersSentSince :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS total
+FROM transfers
+WHERE sender_id = $1
+  AND status = 'succeeded'
+  AND created_at >= $2;
@@ -7,4 +7,10 @@ SELECT * FROM "users" WHERE phone = $1;
 -- name: CreateUser :one
 INSERT INTO "users" (id, phone, pin_hash)
 VALUES ($1, $2, $3)
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: UpdateUserStatus :exec
+UPDATE "users" SET status = $2 WHERE id = $1;
+
+-- name: UpdateUserPin :exec
+UPDATE "users" SET pin_hash = $2 WHERE id = $1;
@@ -0,0 +1,19 @@
+-- name: EnsureWallet :exec
+INSERT INTO wallets (user_id, balance, currency)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetWallet :one
+SELECT * FROM wallets
+WHERE user_id = $1;
+
+-- name: GetWalletForUpdate :one
+SELECT * FROM wallets
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: UpdateWalletBalance :exec
+UPDATE wallets
+SET    balance = $2,
+       updated_at = now()
+WHERE  user_id = $1;
@@ -6,9 +6,10 @@ INSERT INTO webhooks (
     signing_strategy,
     secret,
     events,
-    status
+    status,
+    env
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8
 ) RETURNING *;
 
 -- name: GetWebhookByID :one
@@ -20,6 +21,11 @@ SELECT * FROM webhooks
 WHERE business_id = $1
 ORDER BY created_at DESC;
 
+-- name: ListWebhooksForEnv :many
+SELECT * FROM webhooks
+WHERE business_id = $1 AND env = $2
+ORDER BY created_at DESC;
+
 -- name: UpdateWebhook :one
 UPDATE webhooks
 SET
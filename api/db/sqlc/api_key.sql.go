@@ -11,19 +11,40 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addAPIKeyUsage = `-- name: AddAPIKeyUsage :exec
+INSERT INTO api_key_usage (key_id, day, requests)
+VALUES ($1, $2, $3)
+ON CONFLICT (key_id, day) DO UPDATE
+SET requests = api_key_usage.requests + EXCLUDED.requests
+`
+
+type AddAPIKeyUsageParams struct {
+	KeyID    string      `json:"key_id"`
+	Day      pgtype.Date `json:"day"`
+	Requests int32       `json:"requests"`
+}
+
+func (q *Queries) AddAPIKeyUsage(ctx context.Context, arg AddAPIKeyUsageParams) error {
+	_, err := q.db.Exec(ctx, addAPIKeyUsage, arg.KeyID, arg.Day, arg.Requests)
+	return err
+}
+
 const createAPIKey = `-- name: CreateAPIKey :one
-INSERT INTO api_keys (id, business_id, prefix, key_hash, scopes, env)
-VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, business_id, prefix, key_hash, scopes, env, status, created_at
+INSERT INTO api_keys (id, business_id, prefix, key_hash, scopes, env, key_salt, expires_at, allowed_cidrs)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING id, business_id, prefix, key_hash, scopes, env, status, created_at, key_salt, expires_at, last_used_at, last_used_ip, allowed_cidrs
 `
 
 type CreateAPIKeyParams struct {
-	ID         string   `json:"id"`
-	BusinessID string   `json:"business_id"`
-	Prefix     string   `json:"prefix"`
-	KeyHash    string   `json:"key_hash"`
-	Scopes     []string `json:"scopes"`
-	Env        string   `json:"env"`
+	ID           string             `json:"id"`
+	BusinessID   string             `json:"business_id"`
+	Prefix       string             `json:"prefix"`
+	KeyHash      string             `json:"key_hash"`
+	Scopes       []string           `json:"scopes"`
+	Env          string             `json:"env"`
+	KeySalt      pgtype.Text        `json:"key_salt"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	AllowedCidrs []string           `json:"allowed_cidrs"`
 }
 
 func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error) {
@@ -34,6 +55,9 @@ func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (Api
 		arg.KeyHash,
 		arg.Scopes,
 		arg.Env,
+		arg.KeySalt,
+		arg.ExpiresAt,
+		arg.AllowedCidrs,
 	)
 	var i ApiKey
 	err := row.Scan(
@@ -45,12 +69,34 @@ func (q *Queries) CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (Api
 		&i.Env,
 		&i.Status,
 		&i.CreatedAt,
+		&i.KeySalt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.AllowedCidrs,
 	)
 	return i, err
 }
 
+const expireAPIKey = `-- name: ExpireAPIKey :exec
+UPDATE api_keys
+SET expires_at = $3
+WHERE id = $1 AND business_id = $2
+`
+
+type ExpireAPIKeyParams struct {
+	ID         string             `json:"id"`
+	BusinessID string             `json:"business_id"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) ExpireAPIKey(ctx context.Context, arg ExpireAPIKeyParams) error {
+	_, err := q.db.Exec(ctx, expireAPIKey, arg.ID, arg.BusinessID, arg.ExpiresAt)
+	return err
+}
+
 const getAPIKeyByID = `-- name: GetAPIKeyByID :one
-SELECT id, business_id, prefix, key_hash, scopes, env, status, created_at
+SELECT id, business_id, prefix, key_hash, scopes, env, status, created_at, key_salt, expires_at, last_used_at, last_used_ip, allowed_cidrs
 FROM api_keys
 WHERE id = $1
 `
@@ -67,25 +113,113 @@ func (q *Queries) GetAPIKeyByID(ctx context.Context, id string) (ApiKey, error)
 		&i.Env,
 		&i.Status,
 		&i.CreatedAt,
+		&i.KeySalt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.AllowedCidrs,
 	)
 	return i, err
 }
 
+const getAPIKeyForAuth = `-- name: GetAPIKeyForAuth :one
+SELECT k.id, k.business_id, k.prefix, k.key_hash, k.scopes, k.env, k.status, k.created_at, k.key_salt, k.expires_at, k.last_used_at, k.last_used_ip, k.allowed_cidrs, b.name AS business_name
+FROM api_keys k
+JOIN business b ON k.business_id = b.id
+WHERE k.id = $1
+`
+
+type GetAPIKeyForAuthRow struct {
+	ID           string             `json:"id"`
+	BusinessID   string             `json:"business_id"`
+	Prefix       string             `json:"prefix"`
+	KeyHash      string             `json:"key_hash"`
+	Scopes       []string           `json:"scopes"`
+	Env          string             `json:"env"`
+	Status       pgtype.Text        `json:"status"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	KeySalt      pgtype.Text        `json:"key_salt"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt   pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp   pgtype.Text        `json:"last_used_ip"`
+	AllowedCidrs []string           `json:"allowed_cidrs"`
+	BusinessName string             `json:"business_name"`
+}
+
+func (q *Queries) GetAPIKeyForAuth(ctx context.Context, id string) (GetAPIKeyForAuthRow, error) {
+	row := q.db.QueryRow(ctx, getAPIKeyForAuth, id)
+	var i GetAPIKeyForAuthRow
+	err := row.Scan(
+		&i.ID,
+		&i.BusinessID,
+		&i.Prefix,
+		&i.KeyHash,
+		&i.Scopes,
+		&i.Env,
+		&i.Status,
+		&i.CreatedAt,
+		&i.KeySalt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.AllowedCidrs,
+		&i.BusinessName,
+	)
+	return i, err
+}
+
+const listAPIKeyUsage = `-- name: ListAPIKeyUsage :many
+SELECT u.key_id, u.day, u.requests
+FROM api_key_usage u
+JOIN api_keys k ON k.id = u.key_id
+WHERE k.business_id = $1 AND u.day >= $2
+ORDER BY u.day DESC
+`
+
+type ListAPIKeyUsageParams struct {
+	BusinessID string      `json:"business_id"`
+	Day        pgtype.Date `json:"day"`
+}
+
+func (q *Queries) ListAPIKeyUsage(ctx context.Context, arg ListAPIKeyUsageParams) ([]ApiKeyUsage, error) {
+	rows, err := q.db.Query(ctx, listAPIKeyUsage, arg.BusinessID, arg.Day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ApiKeyUsage
+	for rows.Next() {
+		var i ApiKeyUsage
+		if err := rows.Scan(&i.KeyID, &i.Day, &i.Requests); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAPIKeys = `-- name: ListAPIKeys :many
-SELECT id, business_id, prefix, scopes, env, status, created_at
+SELECT id, business_id, prefix, scopes, env, status, created_at, expires_at, last_used_at, last_used_ip, allowed_cidrs
 FROM api_keys
 WHERE business_id = $1 AND status = 'active'
 ORDER BY created_at DESC
 `
 
 type ListAPIKeysRow struct {
-	ID         string             `json:"id"`
-	BusinessID string             `json:"business_id"`
-	Prefix     string             `json:"prefix"`
-	Scopes     []string           `json:"scopes"`
-	Env        string             `json:"env"`
-	Status     pgtype.Text        `json:"status"`
-	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	ID           string             `json:"id"`
+	BusinessID   string             `json:"business_id"`
+	Prefix       string             `json:"prefix"`
+	Scopes       []string           `json:"scopes"`
+	Env          string             `json:"env"`
+	Status       pgtype.Text        `json:"status"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt   pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp   pgtype.Text        `json:"last_used_ip"`
+	AllowedCidrs []string           `json:"allowed_cidrs"`
 }
 
 func (q *Queries) ListAPIKeys(ctx context.Context, businessID string) ([]ListAPIKeysRow, error) {
@@ -105,6 +239,10 @@ func (q *Queries) ListAPIKeys(ctx context.Context, businessID string) ([]ListAPI
 			&i.Env,
 			&i.Status,
 			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.LastUsedAt,
+			&i.LastUsedIp,
+			&i.AllowedCidrs,
 		); err != nil {
 			return nil, err
 		}
@@ -131,3 +269,20 @@ func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) erro
 	_, err := q.db.Exec(ctx, revokeAPIKey, arg.ID, arg.BusinessID)
 	return err
 }
+
+const touchAPIKey = `-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = $2, last_used_ip = $3
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)
+`
+
+type TouchAPIKeyParams struct {
+	ID         string             `json:"id"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp pgtype.Text        `json:"last_used_ip"`
+}
+
+func (q *Queries) TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error {
+	_, err := q.db.Exec(ctx, touchAPIKey, arg.ID, arg.LastUsedAt, arg.LastUsedIp)
+	return err
+}
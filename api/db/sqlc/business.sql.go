@@ -7,12 +7,14 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createBusiness = `-- name: CreateBusiness :one
 INSERT INTO business (id, name, owner_id, country, currency)
 VALUES ($1, $2, $3, $4, $5)
-RETURNING id, owner_id, name, country, currency, created_at
+RETURNING id, owner_id, name, country, currency, created_at, logo_url, support_email, support_phone, fee_basis_points, fee_min, fee_max
 `
 
 type CreateBusinessParams struct {
@@ -39,12 +41,18 @@ func (q *Queries) CreateBusiness(ctx context.Context, arg CreateBusinessParams)
 		&i.Country,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.LogoUrl,
+		&i.SupportEmail,
+		&i.SupportPhone,
+		&i.FeeBasisPoints,
+		&i.FeeMin,
+		&i.FeeMax,
 	)
 	return i, err
 }
 
 const getBusinessByID = `-- name: GetBusinessByID :one
-SELECT id, owner_id, name, country, currency, created_at
+SELECT id, owner_id, name, country, currency, created_at, logo_url, support_email, support_phone, fee_basis_points, fee_min, fee_max
 FROM business
 WHERE id = $1
 `
@@ -59,18 +67,137 @@ func (q *Queries) GetBusinessByID(ctx context.Context, id string) (Business, err
 		&i.Country,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.LogoUrl,
+		&i.SupportEmail,
+		&i.SupportPhone,
+		&i.FeeBasisPoints,
+		&i.FeeMin,
+		&i.FeeMax,
 	)
 	return i, err
 }
 
-const getBusinessByOwnerID = `-- name: GetBusinessByOwnerID :one
-SELECT id, owner_id, name, country, currency, created_at
-FROM business
-WHERE owner_id = $1
+const listBusinessesForUser = `-- name: ListBusinessesForUser :many
+SELECT b.id, b.owner_id, b.name, b.country, b.currency, b.created_at, m.role
+FROM business b
+JOIN business_members m ON m.business_id = b.id
+WHERE m.user_id = $1
+ORDER BY m.created_at
+`
+
+type ListBusinessesForUserRow struct {
+	ID        string             `json:"id"`
+	OwnerID   string             `json:"owner_id"`
+	Name      string             `json:"name"`
+	Country   string             `json:"country"`
+	Currency  string             `json:"currency"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Role      string             `json:"role"`
+}
+
+func (q *Queries) ListBusinessesForUser(ctx context.Context, userID string) ([]ListBusinessesForUserRow, error) {
+	rows, err := q.db.Query(ctx, listBusinessesForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBusinessesForUserRow
+	for rows.Next() {
+		var i ListBusinessesForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.OwnerID,
+			&i.Name,
+			&i.Country,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateBusinessFees = `-- name: UpdateBusinessFees :one
+UPDATE business
+SET
+    fee_basis_points = $2,
+    fee_min = $3,
+    fee_max = $4
+WHERE id = $1
+RETURNING id, owner_id, name, country, currency, created_at, logo_url, support_email, support_phone, fee_basis_points, fee_min, fee_max
 `
 
-func (q *Queries) GetBusinessByOwnerID(ctx context.Context, ownerID string) (Business, error) {
-	row := q.db.QueryRow(ctx, getBusinessByOwnerID, ownerID)
+type UpdateBusinessFeesParams struct {
+	ID             string      `json:"id"`
+	FeeBasisPoints pgtype.Int4 `json:"fee_basis_points"`
+	FeeMin         pgtype.Int8 `json:"fee_min"`
+	FeeMax         pgtype.Int8 `json:"fee_max"`
+}
+
+func (q *Queries) UpdateBusinessFees(ctx context.Context, arg UpdateBusinessFeesParams) (Business, error) {
+	row := q.db.QueryRow(ctx, updateBusinessFees,
+		arg.ID,
+		arg.FeeBasisPoints,
+		arg.FeeMin,
+		arg.FeeMax,
+	)
+	var i Business
+	err := row.Scan(
+		&i.ID,
+		&i.OwnerID,
+		&i.Name,
+		&i.Country,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.LogoUrl,
+		&i.SupportEmail,
+		&i.SupportPhone,
+		&i.FeeBasisPoints,
+		&i.FeeMin,
+		&i.FeeMax,
+	)
+	return i, err
+}
+
+const updateBusinessProfile = `-- name: UpdateBusinessProfile :one
+UPDATE business
+SET
+    name = $2,
+    logo_url = $3,
+    country = $4,
+    currency = $5,
+    support_email = $6,
+    support_phone = $7
+WHERE id = $1
+RETURNING id, owner_id, name, country, currency, created_at, logo_url, support_email, support_phone, fee_basis_points, fee_min, fee_max
+`
+
+type UpdateBusinessProfileParams struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	LogoUrl      pgtype.Text `json:"logo_url"`
+	Country      string      `json:"country"`
+	Currency     string      `json:"currency"`
+	SupportEmail pgtype.Text `json:"support_email"`
+	SupportPhone pgtype.Text `json:"support_phone"`
+}
+
+func (q *Queries) UpdateBusinessProfile(ctx context.Context, arg UpdateBusinessProfileParams) (Business, error) {
+	row := q.db.QueryRow(ctx, updateBusinessProfile,
+		arg.ID,
+		arg.Name,
+		arg.LogoUrl,
+		arg.Country,
+		arg.Currency,
+		arg.SupportEmail,
+		arg.SupportPhone,
+	)
 	var i Business
 	err := row.Scan(
 		&i.ID,
@@ -79,6 +206,12 @@ func (q *Queries) GetBusinessByOwnerID(ctx context.Context, ownerID string) (Bus
 		&i.Country,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.LogoUrl,
+		&i.SupportEmail,
+		&i.SupportPhone,
+		&i.FeeBasisPoints,
+		&i.FeeMin,
+		&i.FeeMax,
 	)
 	return i, err
 }
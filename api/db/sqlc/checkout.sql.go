@@ -11,6 +11,21 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const businessHasOpenCheckoutSessions = `-- name: BusinessHasOpenCheckoutSessions :one
+SELECT EXISTS (
+    SELECT 1 FROM checkout_sessions
+    WHERE business_id = $1
+      AND status = 'open'
+)
+`
+
+func (q *Queries) BusinessHasOpenCheckoutSessions(ctx context.Context, businessID string) (bool, error) {
+	row := q.db.QueryRow(ctx, businessHasOpenCheckoutSessions, businessID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createCheckoutSession = `-- name: CreateCheckoutSession :one
 INSERT INTO checkout_sessions (
     id,
@@ -26,16 +41,17 @@ INSERT INTO checkout_sessions (
     wave_launch_url,
     transaction_id,
     payment_status,
-    expires_at
+    expires_at,
+    env
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
-) RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+) RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env
 `
 
 type CreateCheckoutSessionParams struct {
 	ID                   string             `json:"id"`
 	BusinessID           string             `json:"business_id"`
-	Amount               string             `json:"amount"`
+	Amount               int64              `json:"amount"`
 	Currency             string             `json:"currency"`
 	ClientReference      pgtype.Text        `json:"client_reference"`
 	AggregatedMerchantID pgtype.Text        `json:"aggregated_merchant_id"`
@@ -47,6 +63,7 @@ type CreateCheckoutSessionParams struct {
 	TransactionID        pgtype.Text        `json:"transaction_id"`
 	PaymentStatus        pgtype.Text        `json:"payment_status"`
 	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
+	Env                  string             `json:"env"`
 }
 
 func (q *Queries) CreateCheckoutSession(ctx context.Context, arg CreateCheckoutSessionParams) (CheckoutSession, error) {
@@ -65,6 +82,7 @@ func (q *Queries) CreateCheckoutSession(ctx context.Context, arg CreateCheckoutS
 		arg.TransactionID,
 		arg.PaymentStatus,
 		arg.ExpiresAt,
+		arg.Env,
 	)
 	var i CheckoutSession
 	err := row.Scan(
@@ -85,6 +103,7 @@ func (q *Queries) CreateCheckoutSession(ctx context.Context, arg CreateCheckoutS
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
 	)
 	return i, err
 }
@@ -96,19 +115,36 @@ INSERT INTO payments (
     amount,
     currency,
     status,
-    failure_reason
+    failure_reason,
+    user_id,
+    payer_mobile,
+    business_id,
+    channel,
+    env,
+    fee,
+    net_amount,
+    settles_at,
+    completed_at
 ) VALUES (
-    $1, $2, $3, $4, $5, $6
-) RETURNING id, session_id, amount, currency, status, failure_reason, completed_at, created_at
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, now()
+) RETURNING id, session_id, amount, currency, status, failure_reason, completed_at, created_at, user_id, payer_mobile, refunded_at, business_id, channel, env, fee, net_amount, settles_at, settlement_id, settled_at
 `
 
 type CreatePaymentParams struct {
-	ID            string      `json:"id"`
-	SessionID     string      `json:"session_id"`
-	Amount        string      `json:"amount"`
-	Currency      string      `json:"currency"`
-	Status        string      `json:"status"`
-	FailureReason pgtype.Text `json:"failure_reason"`
+	ID            string             `json:"id"`
+	SessionID     pgtype.Text        `json:"session_id"`
+	Amount        int64              `json:"amount"`
+	Currency      string             `json:"currency"`
+	Status        string             `json:"status"`
+	FailureReason pgtype.Text        `json:"failure_reason"`
+	UserID        pgtype.Text        `json:"user_id"`
+	PayerMobile   pgtype.Text        `json:"payer_mobile"`
+	BusinessID    string             `json:"business_id"`
+	Channel       string             `json:"channel"`
+	Env           string             `json:"env"`
+	Fee           int64              `json:"fee"`
+	NetAmount     int64              `json:"net_amount"`
+	SettlesAt     pgtype.Timestamptz `json:"settles_at"`
 }
 
 func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
@@ -119,6 +155,14 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		arg.Currency,
 		arg.Status,
 		arg.FailureReason,
+		arg.UserID,
+		arg.PayerMobile,
+		arg.BusinessID,
+		arg.Channel,
+		arg.Env,
+		arg.Fee,
+		arg.NetAmount,
+		arg.SettlesAt,
 	)
 	var i Payment
 	err := row.Scan(
@@ -130,6 +174,17 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		&i.FailureReason,
 		&i.CompletedAt,
 		&i.CreatedAt,
+		&i.UserID,
+		&i.PayerMobile,
+		&i.RefundedAt,
+		&i.BusinessID,
+		&i.Channel,
+		&i.Env,
+		&i.Fee,
+		&i.NetAmount,
+		&i.SettlesAt,
+		&i.SettlementID,
+		&i.SettledAt,
 	)
 	return i, err
 }
@@ -158,7 +213,8 @@ UPDATE checkout_sessions
 SET    payment_status = 'cancelled',
        last_payment_error = $2
 WHERE  id = $1
-RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created
+  AND  status = 'open'
+RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env
 `
 
 type FailCheckoutSessionParams struct {
@@ -187,12 +243,13 @@ func (q *Queries) FailCheckoutSession(ctx context.Context, arg FailCheckoutSessi
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
 	)
 	return i, err
 }
 
 const getAPIKeyByPrefixAndSecret = `-- name: GetAPIKeyByPrefixAndSecret :one
-SELECT k.id, k.business_id, k.prefix, k.key_hash, k.scopes, k.env, k.status, k.created_at, b.id as business_id_alias, b.name as business_name
+SELECT k.id, k.business_id, k.prefix, k.key_hash, k.scopes, k.env, k.status, k.created_at, k.key_salt, k.expires_at, k.last_used_at, k.last_used_ip, k.allowed_cidrs, b.id as business_id_alias, b.name as business_name
 FROM api_keys k
 JOIN business b ON k.business_id = b.id
 WHERE k.prefix = $1 AND k.key_hash = $2
@@ -212,6 +269,11 @@ type GetAPIKeyByPrefixAndSecretRow struct {
 	Env             string             `json:"env"`
 	Status          pgtype.Text        `json:"status"`
 	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	KeySalt         pgtype.Text        `json:"key_salt"`
+	ExpiresAt       pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt      pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp      pgtype.Text        `json:"last_used_ip"`
+	AllowedCidrs    []string           `json:"allowed_cidrs"`
 	BusinessIDAlias string             `json:"business_id_alias"`
 	BusinessName    string             `json:"business_name"`
 }
@@ -228,6 +290,11 @@ func (q *Queries) GetAPIKeyByPrefixAndSecret(ctx context.Context, arg GetAPIKeyB
 		&i.Env,
 		&i.Status,
 		&i.CreatedAt,
+		&i.KeySalt,
+		&i.ExpiresAt,
+		&i.LastUsedAt,
+		&i.LastUsedIp,
+		&i.AllowedCidrs,
 		&i.BusinessIDAlias,
 		&i.BusinessName,
 	)
@@ -235,17 +302,18 @@ func (q *Queries) GetAPIKeyByPrefixAndSecret(ctx context.Context, arg GetAPIKeyB
 }
 
 const getCheckoutSession = `-- name: GetCheckoutSession :one
-SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created FROM checkout_sessions
-WHERE id = $1 AND business_id = $2
+SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env FROM checkout_sessions
+WHERE id = $1 AND business_id = $2 AND env = $3
 `
 
 type GetCheckoutSessionParams struct {
 	ID         string `json:"id"`
 	BusinessID string `json:"business_id"`
+	Env        string `json:"env"`
 }
 
 func (q *Queries) GetCheckoutSession(ctx context.Context, arg GetCheckoutSessionParams) (CheckoutSession, error) {
-	row := q.db.QueryRow(ctx, getCheckoutSession, arg.ID, arg.BusinessID)
+	row := q.db.QueryRow(ctx, getCheckoutSession, arg.ID, arg.BusinessID, arg.Env)
 	var i CheckoutSession
 	err := row.Scan(
 		&i.ID,
@@ -265,12 +333,13 @@ func (q *Queries) GetCheckoutSession(ctx context.Context, arg GetCheckoutSession
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
 	)
 	return i, err
 }
 
 const getCheckoutSessionByID = `-- name: GetCheckoutSessionByID :one
-SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created FROM checkout_sessions
+SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env FROM checkout_sessions
 WHERE id = $1
 `
 
@@ -295,23 +364,25 @@ func (q *Queries) GetCheckoutSessionByID(ctx context.Context, id string) (Checko
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
 	)
 	return i, err
 }
 
 const getCheckoutSessionByTxID = `-- name: GetCheckoutSessionByTxID :one
-SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created FROM checkout_sessions
-WHERE transaction_id = $1 AND business_id = $2
+SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env FROM checkout_sessions
+WHERE transaction_id = $1 AND business_id = $2 AND env = $3
 LIMIT 1
 `
 
 type GetCheckoutSessionByTxIDParams struct {
 	TransactionID pgtype.Text `json:"transaction_id"`
 	BusinessID    string      `json:"business_id"`
+	Env           string      `json:"env"`
 }
 
 func (q *Queries) GetCheckoutSessionByTxID(ctx context.Context, arg GetCheckoutSessionByTxIDParams) (CheckoutSession, error) {
-	row := q.db.QueryRow(ctx, getCheckoutSessionByTxID, arg.TransactionID, arg.BusinessID)
+	row := q.db.QueryRow(ctx, getCheckoutSessionByTxID, arg.TransactionID, arg.BusinessID, arg.Env)
 	var i CheckoutSession
 	err := row.Scan(
 		&i.ID,
@@ -331,24 +402,116 @@ func (q *Queries) GetCheckoutSessionByTxID(ctx context.Context, arg GetCheckoutS
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
+	)
+	return i, err
+}
+
+const listBusinessPayments = `-- name: ListBusinessPayments :many
+SELECT id, session_id, amount, currency, status, failure_reason, completed_at, created_at, user_id, payer_mobile, refunded_at, business_id, channel, env, fee, net_amount, settles_at, settlement_id, settled_at FROM payments
+WHERE business_id = $1
+  AND env = $2
+  AND status = 'succeeded'
+ORDER BY created_at DESC, id DESC
+LIMIT $3 OFFSET $4
+`
+
+type ListBusinessPaymentsParams struct {
+	BusinessID string `json:"business_id"`
+	Env        string `json:"env"`
+	Limit      int32  `json:"limit"`
+	Offset     int32  `json:"offset"`
+}
+
+func (q *Queries) ListBusinessPayments(ctx context.Context, arg ListBusinessPaymentsParams) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listBusinessPayments,
+		arg.BusinessID,
+		arg.Env,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Payment
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.SessionID,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.FailureReason,
+			&i.CompletedAt,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.PayerMobile,
+			&i.RefundedAt,
+			&i.BusinessID,
+			&i.Channel,
+			&i.Env,
+			&i.Fee,
+			&i.NetAmount,
+			&i.SettlesAt,
+			&i.SettlementID,
+			&i.SettledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const refundSessionPayment = `-- name: RefundSessionPayment :one
+UPDATE payments
+SET    refunded_at = now()
+WHERE  session_id = $1
+  AND  status = 'succeeded'
+  AND  refunded_at IS NULL
+RETURNING amount, net_amount, settled_at, user_id
+`
+
+type RefundSessionPaymentRow struct {
+	Amount    int64              `json:"amount"`
+	NetAmount int64              `json:"net_amount"`
+	SettledAt pgtype.Timestamptz `json:"settled_at"`
+	UserID    pgtype.Text        `json:"user_id"`
+}
+
+func (q *Queries) RefundSessionPayment(ctx context.Context, sessionID pgtype.Text) (RefundSessionPaymentRow, error) {
+	row := q.db.QueryRow(ctx, refundSessionPayment, sessionID)
+	var i RefundSessionPaymentRow
+	err := row.Scan(
+		&i.Amount,
+		&i.NetAmount,
+		&i.SettledAt,
+		&i.UserID,
 	)
 	return i, err
 }
 
 const searchCheckoutSessions = `-- name: SearchCheckoutSessions :many
-SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created FROM checkout_sessions
+SELECT id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env FROM checkout_sessions
 WHERE business_id = $1
   AND client_reference = $2
+  AND env = $3
 ORDER BY when_created DESC
 `
 
 type SearchCheckoutSessionsParams struct {
 	BusinessID      string      `json:"business_id"`
 	ClientReference pgtype.Text `json:"client_reference"`
+	Env             string      `json:"env"`
 }
 
 func (q *Queries) SearchCheckoutSessions(ctx context.Context, arg SearchCheckoutSessionsParams) ([]CheckoutSession, error) {
-	rows, err := q.db.Query(ctx, searchCheckoutSessions, arg.BusinessID, arg.ClientReference)
+	rows, err := q.db.Query(ctx, searchCheckoutSessions, arg.BusinessID, arg.ClientReference, arg.Env)
 	if err != nil {
 		return nil, err
 	}
@@ -374,6 +537,7 @@ func (q *Queries) SearchCheckoutSessions(ctx context.Context, arg SearchCheckout
 			&i.ExpiresAt,
 			&i.WhenCompleted,
 			&i.WhenCreated,
+			&i.Env,
 		); err != nil {
 			return nil, err
 		}
@@ -391,7 +555,8 @@ SET    status = 'complete',
        payment_status = 'succeeded',
        when_completed = now()
 WHERE  id = $1
-RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created
+  AND  status = 'open'
+RETURNING id, business_id, amount, currency, client_reference, aggregated_merchant_id, status, error_url, success_url, restrict_payer_mobile, wave_launch_url, transaction_id, payment_status, last_payment_error, expires_at, when_completed, when_created, env
 `
 
 func (q *Queries) SucceedCheckoutSession(ctx context.Context, id string) (CheckoutSession, error) {
@@ -415,6 +580,7 @@ func (q *Queries) SucceedCheckoutSession(ctx context.Context, id string) (Checko
 		&i.ExpiresAt,
 		&i.WhenCompleted,
 		&i.WhenCreated,
+		&i.Env,
 	)
 	return i, err
 }
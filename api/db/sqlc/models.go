@@ -9,47 +9,93 @@ import (
 )
 
 type ApiKey struct {
-	ID         string             `json:"id"`
-	BusinessID string             `json:"business_id"`
-	Prefix     string             `json:"prefix"`
-	KeyHash    string             `json:"key_hash"`
-	Scopes     []string           `json:"scopes"`
-	Env        string             `json:"env"`
-	Status     pgtype.Text        `json:"status"`
-	CreatedAt  pgtype.Timestamptz `json:"created_at"`
-}
-
-type B2bTransfer struct {
 	ID           string             `json:"id"`
 	BusinessID   string             `json:"business_id"`
-	Counterparty string             `json:"counterparty"`
-	Amount       string             `json:"amount"`
-	Currency     string             `json:"currency"`
-	Status       string             `json:"status"`
-	Reference    pgtype.Text        `json:"reference"`
+	Prefix       string             `json:"prefix"`
+	KeyHash      string             `json:"key_hash"`
+	Scopes       []string           `json:"scopes"`
+	Env          string             `json:"env"`
+	Status       pgtype.Text        `json:"status"`
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	KeySalt      pgtype.Text        `json:"key_salt"`
+	ExpiresAt    pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt   pgtype.Timestamptz `json:"last_used_at"`
+	LastUsedIp   pgtype.Text        `json:"last_used_ip"`
+	AllowedCidrs []string           `json:"allowed_cidrs"`
+}
+
+type ApiKeyUsage struct {
+	KeyID    string      `json:"key_id"`
+	Day      pgtype.Date `json:"day"`
+	Requests int32       `json:"requests"`
+}
+
+type AuditLog struct {
+	ID        string             `json:"id"`
+	UserID    string             `json:"user_id"`
+	Event     string             `json:"event"`
+	Ip        pgtype.Text        `json:"ip"`
+	Details   []byte             `json:"details"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type B2bTransfer struct {
+	ID            string             `json:"id"`
+	BusinessID    string             `json:"business_id"`
+	Counterparty  string             `json:"counterparty"`
+	Amount        int64              `json:"amount"`
+	Currency      string             `json:"currency"`
+	Status        string             `json:"status"`
+	Reference     pgtype.Text        `json:"reference"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	FailureReason pgtype.Text        `json:"failure_reason"`
+	Env           string             `json:"env"`
 }
 
 type Balance struct {
 	BusinessID string `json:"business_id"`
-	Available  string `json:"available"`
-	Pending    string `json:"pending"`
+	Available  int64  `json:"available"`
+	Pending    int64  `json:"pending"`
 	Currency   string `json:"currency"`
+	Env        string `json:"env"`
 }
 
 type Business struct {
-	ID        string             `json:"id"`
-	OwnerID   string             `json:"owner_id"`
-	Name      string             `json:"name"`
-	Country   string             `json:"country"`
-	Currency  string             `json:"currency"`
-	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ID             string             `json:"id"`
+	OwnerID        string             `json:"owner_id"`
+	Name           string             `json:"name"`
+	Country        string             `json:"country"`
+	Currency       string             `json:"currency"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	LogoUrl        pgtype.Text        `json:"logo_url"`
+	SupportEmail   pgtype.Text        `json:"support_email"`
+	SupportPhone   pgtype.Text        `json:"support_phone"`
+	FeeBasisPoints pgtype.Int4        `json:"fee_basis_points"`
+	FeeMin         pgtype.Int8        `json:"fee_min"`
+	FeeMax         pgtype.Int8        `json:"fee_max"`
+}
+
+type BusinessInvitation struct {
+	ID         string             `json:"id"`
+	BusinessID string             `json:"business_id"`
+	Phone      string             `json:"phone"`
+	Role       string             `json:"role"`
+	InvitedBy  string             `json:"invited_by"`
+	Status     string             `json:"status"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type BusinessMember struct {
+	BusinessID string             `json:"business_id"`
+	UserID     string             `json:"user_id"`
+	Role       string             `json:"role"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 }
 
 type CheckoutSession struct {
 	ID                   string             `json:"id"`
 	BusinessID           string             `json:"business_id"`
-	Amount               string             `json:"amount"`
+	Amount               int64              `json:"amount"`
 	Currency             string             `json:"currency"`
 	ClientReference      pgtype.Text        `json:"client_reference"`
 	AggregatedMerchantID pgtype.Text        `json:"aggregated_merchant_id"`
@@ -64,17 +110,51 @@ type CheckoutSession struct {
 	ExpiresAt            pgtype.Timestamptz `json:"expires_at"`
 	WhenCompleted        pgtype.Timestamptz `json:"when_completed"`
 	WhenCreated          pgtype.Timestamptz `json:"when_created"`
+	Env                  string             `json:"env"`
 }
 
 type Payment struct {
 	ID            string             `json:"id"`
-	SessionID     string             `json:"session_id"`
-	Amount        string             `json:"amount"`
+	SessionID     pgtype.Text        `json:"session_id"`
+	Amount        int64              `json:"amount"`
 	Currency      string             `json:"currency"`
 	Status        string             `json:"status"`
 	FailureReason pgtype.Text        `json:"failure_reason"`
 	CompletedAt   pgtype.Timestamptz `json:"completed_at"`
 	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UserID        pgtype.Text        `json:"user_id"`
+	PayerMobile   pgtype.Text        `json:"payer_mobile"`
+	RefundedAt    pgtype.Timestamptz `json:"refunded_at"`
+	BusinessID    string             `json:"business_id"`
+	Channel       string             `json:"channel"`
+	Env           string             `json:"env"`
+	Fee           int64              `json:"fee"`
+	NetAmount     int64              `json:"net_amount"`
+	SettlesAt     pgtype.Timestamptz `json:"settles_at"`
+	SettlementID  pgtype.Text        `json:"settlement_id"`
+	SettledAt     pgtype.Timestamptz `json:"settled_at"`
+}
+
+type Settlement struct {
+	ID           string             `json:"id"`
+	BusinessID   string             `json:"business_id"`
+	Env          string             `json:"env"`
+	Amount       int64              `json:"amount"`
+	Currency     string             `json:"currency"`
+	PaymentCount int32              `json:"payment_count"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type Transfer struct {
+	ID          string             `json:"id"`
+	SenderID    string             `json:"sender_id"`
+	RecipientID string             `json:"recipient_id"`
+	Amount      int64              `json:"amount"`
+	Fee         int64              `json:"fee"`
+	Currency    string             `json:"currency"`
+	Status      string             `json:"status"`
+	Note        pgtype.Text        `json:"note"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 }
 
 type User struct {
@@ -83,6 +163,14 @@ type User struct {
 	PinHash   string             `json:"pin_hash"`
 	Status    string             `json:"status"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Tier      string             `json:"tier"`
+}
+
+type Wallet struct {
+	UserID    string             `json:"user_id"`
+	Balance   int64              `json:"balance"`
+	Currency  string             `json:"currency"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
 }
 
 type Webhook struct {
@@ -95,6 +183,7 @@ type Webhook struct {
 	Status          string             `json:"status"`
 	CreatedAt       pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	Env             string             `json:"env"`
 }
 
 type WebhookDelivery struct {
@@ -6,34 +6,81 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AddAPIKeyUsage(ctx context.Context, arg AddAPIKeyUsageParams) error
+	BusinessHasBalance(ctx context.Context, businessID string) (bool, error)
+	BusinessHasOpenCheckoutSessions(ctx context.Context, businessID string) (bool, error)
 	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) error
+	CreateB2BTransfer(ctx context.Context, arg CreateB2BTransferParams) (B2bTransfer, error)
 	CreateBusiness(ctx context.Context, arg CreateBusinessParams) (Business, error)
+	CreateBusinessInvitation(ctx context.Context, arg CreateBusinessInvitationParams) (BusinessInvitation, error)
+	CreateBusinessMember(ctx context.Context, arg CreateBusinessMemberParams) error
 	CreateCheckoutSession(ctx context.Context, arg CreateCheckoutSessionParams) (CheckoutSession, error)
 	CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error)
+	CreateSettlement(ctx context.Context, arg CreateSettlementParams) (Settlement, error)
+	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
 	CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error)
+	CreditBalance(ctx context.Context, arg CreditBalanceParams) (Balance, error)
+	CreditPendingBalance(ctx context.Context, arg CreditPendingBalanceParams) (Balance, error)
+	DebitBalance(ctx context.Context, arg DebitBalanceParams) (Balance, error)
+	DebitPendingBalance(ctx context.Context, arg DebitPendingBalanceParams) (Balance, error)
+	DeleteBusinessMember(ctx context.Context, arg DeleteBusinessMemberParams) error
 	DeleteWebhook(ctx context.Context, id string) error
+	EnsureWallet(ctx context.Context, arg EnsureWalletParams) error
+	ExpireAPIKey(ctx context.Context, arg ExpireAPIKeyParams) error
 	ExpireCheckoutSession(ctx context.Context, arg ExpireCheckoutSessionParams) error
 	FailCheckoutSession(ctx context.Context, arg FailCheckoutSessionParams) (CheckoutSession, error)
 	GetAPIKeyByID(ctx context.Context, id string) (ApiKey, error)
 	GetAPIKeyByPrefixAndSecret(ctx context.Context, arg GetAPIKeyByPrefixAndSecretParams) (GetAPIKeyByPrefixAndSecretRow, error)
+	GetAPIKeyForAuth(ctx context.Context, id string) (GetAPIKeyForAuthRow, error)
+	GetB2BTransfer(ctx context.Context, arg GetB2BTransferParams) (GetB2BTransferRow, error)
+	GetBalance(ctx context.Context, arg GetBalanceParams) (Balance, error)
 	GetBusinessByID(ctx context.Context, id string) (Business, error)
-	GetBusinessByOwnerID(ctx context.Context, ownerID string) (Business, error)
+	GetBusinessInvitation(ctx context.Context, id string) (BusinessInvitation, error)
+	GetBusinessMember(ctx context.Context, arg GetBusinessMemberParams) (BusinessMember, error)
 	GetCheckoutSession(ctx context.Context, arg GetCheckoutSessionParams) (CheckoutSession, error)
 	GetCheckoutSessionByID(ctx context.Context, id string) (CheckoutSession, error)
 	GetCheckoutSessionByTxID(ctx context.Context, arg GetCheckoutSessionByTxIDParams) (CheckoutSession, error)
+	GetTransferForUser(ctx context.Context, arg GetTransferForUserParams) (GetTransferForUserRow, error)
 	GetUserByID(ctx context.Context, id string) (User, error)
 	GetUserByPhone(ctx context.Context, phone string) (User, error)
+	GetWallet(ctx context.Context, userID string) (Wallet, error)
+	GetWalletForUpdate(ctx context.Context, userID string) (Wallet, error)
 	GetWebhookByID(ctx context.Context, arg GetWebhookByIDParams) (Webhook, error)
+	ListAPIKeyUsage(ctx context.Context, arg ListAPIKeyUsageParams) ([]ApiKeyUsage, error)
 	ListAPIKeys(ctx context.Context, businessID string) ([]ListAPIKeysRow, error)
+	ListB2BTransfers(ctx context.Context, arg ListB2BTransfersParams) ([]ListB2BTransfersRow, error)
+	ListBusinessInvitations(ctx context.Context, businessID string) ([]BusinessInvitation, error)
+	ListBusinessMembers(ctx context.Context, businessID string) ([]ListBusinessMembersRow, error)
+	ListBusinessPayments(ctx context.Context, arg ListBusinessPaymentsParams) ([]Payment, error)
+	ListBusinessesForUser(ctx context.Context, userID string) ([]ListBusinessesForUserRow, error)
+	ListBusinessesToSettle(ctx context.Context, settlesAt pgtype.Timestamptz) ([]ListBusinessesToSettleRow, error)
+	ListInvitationsForPhone(ctx context.Context, phone string) ([]ListInvitationsForPhoneRow, error)
+	ListUserTransactions(ctx context.Context, arg ListUserTransactionsParams) ([]ListUserTransactionsRow, error)
 	ListWebhooksByBusinessID(ctx context.Context, businessID string) ([]Webhook, error)
+	ListWebhooksForEnv(ctx context.Context, arg ListWebhooksForEnvParams) ([]Webhook, error)
+	RefundSessionPayment(ctx context.Context, sessionID pgtype.Text) (RefundSessionPaymentRow, error)
 	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) error
 	SearchCheckoutSessions(ctx context.Context, arg SearchCheckoutSessionsParams) ([]CheckoutSession, error)
+	SettleBalance(ctx context.Context, arg SettleBalanceParams) (Balance, error)
+	SettlePayments(ctx context.Context, arg SettlePaymentsParams) ([]SettlePaymentsRow, error)
 	SucceedCheckoutSession(ctx context.Context, id string) (CheckoutSession, error)
+	SumTransfersSentSince(ctx context.Context, arg SumTransfersSentSinceParams) (int64, error)
+	TouchAPIKey(ctx context.Context, arg TouchAPIKeyParams) error
+	UpdateBusinessFees(ctx context.Context, arg UpdateBusinessFeesParams) (Business, error)
+	UpdateBusinessInvitationStatus(ctx context.Context, arg UpdateBusinessInvitationStatusParams) error
+	UpdateBusinessMemberRole(ctx context.Context, arg UpdateBusinessMemberRoleParams) error
+	UpdateBusinessProfile(ctx context.Context, arg UpdateBusinessProfileParams) (Business, error)
 	UpdateCheckoutPaymentStatus(ctx context.Context, arg UpdateCheckoutPaymentStatusParams) error
+	UpdateUserPin(ctx context.Context, arg UpdateUserPinParams) error
+	UpdateUserStatus(ctx context.Context, arg UpdateUserStatusParams) error
+	UpdateWalletBalance(ctx context.Context, arg UpdateWalletBalanceParams) error
 	UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error)
 }
 
@@ -12,7 +12,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO "users" (id, phone, pin_hash)
 VALUES ($1, $2, $3)
-RETURNING id, phone, pin_hash, status, created_at
+RETURNING id, phone, pin_hash, status, created_at, tier
 `
 
 type CreateUserParams struct {
@@ -30,12 +30,13 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.PinHash,
 		&i.Status,
 		&i.CreatedAt,
+		&i.Tier,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, phone, pin_hash, status, created_at FROM "users" WHERE id = $1
+SELECT id, phone, pin_hash, status, created_at, tier FROM "users" WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
@@ -47,12 +48,13 @@ func (q *Queries) GetUserByID(ctx context.Context, id string) (User, error) {
 		&i.PinHash,
 		&i.Status,
 		&i.CreatedAt,
+		&i.Tier,
 	)
 	return i, err
 }
 
 const getUserByPhone = `-- name: GetUserByPhone :one
-SELECT id, phone, pin_hash, status, created_at FROM "users" WHERE phone = $1
+SELECT id, phone, pin_hash, status, created_at, tier FROM "users" WHERE phone = $1
 `
 
 func (q *Queries) GetUserByPhone(ctx context.Context, phone string) (User, error) {
@@ -64,6 +66,35 @@ func (q *Queries) GetUserByPhone(ctx context.Context, phone string) (User, error
 		&i.PinHash,
 		&i.Status,
 		&i.CreatedAt,
+		&i.Tier,
 	)
 	return i, err
 }
+
+const updateUserPin = `-- name: UpdateUserPin :exec
+UPDATE "users" SET pin_hash = $2 WHERE id = $1
+`
+
+type UpdateUserPinParams struct {
+	ID      string `json:"id"`
+	PinHash string `json:"pin_hash"`
+}
+
+func (q *Queries) UpdateUserPin(ctx context.Context, arg UpdateUserPinParams) error {
+	_, err := q.db.Exec(ctx, updateUserPin, arg.ID, arg.PinHash)
+	return err
+}
+
+const updateUserStatus = `-- name: UpdateUserStatus :exec
+UPDATE "users" SET status = $2 WHERE id = $1
+`
+
+type UpdateUserStatusParams struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateUserStatus(ctx context.Context, arg UpdateUserStatusParams) error {
+	_, err := q.db.Exec(ctx, updateUserStatus, arg.ID, arg.Status)
+	return err
+}
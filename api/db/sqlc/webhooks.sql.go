@@ -17,10 +17,11 @@ INSERT INTO webhooks (
     signing_strategy,
     secret,
     events,
-    status
+    status,
+    env
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at, env
 `
 
 type CreateWebhookParams struct {
@@ -31,6 +32,7 @@ type CreateWebhookParams struct {
 	Secret          string   `json:"secret"`
 	Events          []string `json:"events"`
 	Status          string   `json:"status"`
+	Env             string   `json:"env"`
 }
 
 func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
@@ -42,6 +44,7 @@ func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (W
 		arg.Secret,
 		arg.Events,
 		arg.Status,
+		arg.Env,
 	)
 	var i Webhook
 	err := row.Scan(
@@ -54,6 +57,7 @@ func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (W
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Env,
 	)
 	return i, err
 }
@@ -69,7 +73,7 @@ func (q *Queries) DeleteWebhook(ctx context.Context, id string) error {
 }
 
 const getWebhookByID = `-- name: GetWebhookByID :one
-SELECT id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at FROM webhooks
+SELECT id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at, env FROM webhooks
 WHERE id = $1 AND business_id = $2
 `
 
@@ -91,12 +95,13 @@ func (q *Queries) GetWebhookByID(ctx context.Context, arg GetWebhookByIDParams)
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Env,
 	)
 	return i, err
 }
 
 const listWebhooksByBusinessID = `-- name: ListWebhooksByBusinessID :many
-SELECT id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at FROM webhooks
+SELECT id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at, env FROM webhooks
 WHERE business_id = $1
 ORDER BY created_at DESC
 `
@@ -120,6 +125,49 @@ func (q *Queries) ListWebhooksByBusinessID(ctx context.Context, businessID strin
 			&i.Status,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Env,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooksForEnv = `-- name: ListWebhooksForEnv :many
+SELECT id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at, env FROM webhooks
+WHERE business_id = $1 AND env = $2
+ORDER BY created_at DESC
+`
+
+type ListWebhooksForEnvParams struct {
+	BusinessID string `json:"business_id"`
+	Env        string `json:"env"`
+}
+
+func (q *Queries) ListWebhooksForEnv(ctx context.Context, arg ListWebhooksForEnvParams) ([]Webhook, error) {
+	rows, err := q.db.Query(ctx, listWebhooksForEnv, arg.BusinessID, arg.Env)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.BusinessID,
+			&i.Url,
+			&i.SigningStrategy,
+			&i.Secret,
+			&i.Events,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Env,
 		); err != nil {
 			return nil, err
 		}
@@ -140,7 +188,7 @@ SET
     status = $5,
     updated_at = NOW()
 WHERE id = $1
-RETURNING id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at
+RETURNING id, business_id, url, signing_strategy, secret, events, status, created_at, updated_at, env
 `
 
 type UpdateWebhookParams struct {
@@ -170,6 +218,7 @@ func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (W
 		&i.Status,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Env,
 	)
 	return i, err
 }
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// CreateB2BPaymentRequest represents the request body for paying another business.
+type CreateB2BPaymentRequest struct {
+	RecipientBusinessID string `json:"recipient_business_id" validate:"required,len=27"`
+	Amount              string `json:"amount" validate:"required"`
+	Currency            string `json:"currency" validate:"required,iso4217"`
+	ClientReference     string `json:"client_reference,omitempty" validate:"max=255"`
+}
+
+// B2BPayment represents a payment between two businesses, as returned by the
+// API and sent in b2b.* webhook events.
+type B2BPayment struct {
+	ID                  string            `json:"id"`
+	SenderBusinessID    string            `json:"sender_business_id"`
+	SenderName          string            `json:"sender_name"`
+	RecipientBusinessID string            `json:"recipient_business_id"`
+	RecipientName       string            `json:"recipient_name"`
+	Amount              string            `json:"amount"`
+	Currency            string            `json:"currency"`
+	ClientReference     *string           `json:"client_reference,omitempty"`
+	Status              string            `json:"status"`
+	LastPaymentError    *LastPaymentError `json:"last_payment_error,omitempty"`
+	WhenCreated         time.Time         `json:"when_created"`
+}
+
+// BalanceResponse represents the balance of a business wallet. Amount is
+// available to spend; Pending holds payments received but not yet settled.
+type BalanceResponse struct {
+	Amount   string `json:"amount"`
+	Pending  string `json:"pending"`
+	Currency string `json:"currency"`
+}
+
+// BalanceTransaction represents a payment received by a business, with the
+// fee withheld from it and the net amount credited to its balance.
+type BalanceTransaction struct {
+	ID                string     `json:"id"`
+	Channel           string     `json:"channel"`
+	CheckoutSessionID *string    `json:"checkout_session_id,omitempty"`
+	Amount            string     `json:"amount"`
+	Fee               string     `json:"fee"`
+	NetAmount         string     `json:"net_amount"`
+	Currency          string     `json:"currency"`
+	PayerMobile       *string    `json:"payer_mobile,omitempty"`
+	SettlementID      *string    `json:"settlement_id,omitempty"`
+	WhenCreated       time.Time  `json:"when_created"`
+	WhenAvailable     *time.Time `json:"when_available,omitempty"`
+	WhenSettled       *time.Time `json:"when_settled,omitempty"`
+	WhenRefunded      *time.Time `json:"when_refunded,omitempty"`
+}
+
+// Settlement represents the payments of a business moved from its pending
+// to its available balance in one run, as sent in settlement.completed
+// webhook events.
+type Settlement struct {
+	ID           string    `json:"id"`
+	BusinessID   string    `json:"business_id"`
+	Amount       string    `json:"amount"`
+	Currency     string    `json:"currency"`
+	PaymentCount int       `json:"payment_count"`
+	PaymentIDs   []string  `json:"payment_ids"`
+	WhenCreated  time.Time `json:"when_created"`
+}
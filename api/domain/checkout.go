@@ -4,7 +4,7 @@ import "time"
 
 // CreateCheckoutSessionRequest represents the request body for creating a new checkout session.
 type CreateCheckoutSessionRequest struct {
-	Amount               string `json:"amount" validate:"required,numeric"`
+	Amount               string `json:"amount" validate:"required"`
 	Currency             string `json:"currency" validate:"required,iso4217"`
 	ClientReference      string `json:"client_reference,omitempty" validate:"max=255"`
 	RestrictPayerMobile  string `json:"restrict_payer_mobile,omitempty" validate:"e164"`
@@ -40,3 +40,27 @@ type CheckoutSessionResponse struct {
 	WhenExpires          time.Time         `json:"when_expires"`
 	RestrictPayerMobile  *string           `json:"restrict_payer_mobile,omitempty"`
 }
+
+// PaymentQR is the payload encoded in the QR code of a payment page. Type is
+// "checkout" for a checkout session or "merchant" for a business' static QR.
+// The token signs the id so the mobile app can only act on codes it scanned.
+type PaymentQR struct {
+	Type       string `json:"type"`
+	SessionID  string `json:"session_id,omitempty"`
+	BusinessID string `json:"business_id,omitempty"`
+	Env        string `json:"env,omitempty"`
+	Token      string `json:"token"`
+}
+
+// MerchantPayment is the data of a merchant.payment_received event, sent when a
+// customer pays a business on its static QR code.
+type MerchantPayment struct {
+	ID           string    `json:"id"`
+	MerchantID   string    `json:"merchant_id"`
+	Amount       string    `json:"amount"`
+	Fee          string    `json:"fee"`
+	NetAmount    string    `json:"net_amount"`
+	Currency     string    `json:"currency"`
+	SenderMobile string    `json:"sender_mobile"`
+	WhenCreated  time.Time `json:"when_created"`
+}
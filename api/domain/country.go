@@ -0,0 +1,176 @@
+package domain
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultCountry is assumed for phone numbers given without a dial code, as
+// the mobile app was first released in Senegal.
+const DefaultCountry = "SN"
+
+// Fee is a share of an amount in basis points, bounded by Min and by Max when
+// Max is not zero. Bounds are in minor units of the currency.
+type Fee struct {
+	BasisPoints int64
+	Min         int64
+	Max         int64
+}
+
+// Of returns the fee on amount, in minor units, rounded up to the nearest
+// minor unit. Whole multiples of 10000 are split off before multiplying so
+// that amounts up to MaxMinorAmount can't overflow.
+func (f Fee) Of(amount int64) int64 {
+	whole, rest := amount/10000, amount%10000
+	fee := max(whole*f.BasisPoints+(rest*f.BasisPoints+9999)/10000, f.Min)
+	if f.Max > 0 {
+		fee = min(fee, f.Max)
+	}
+	return fee
+}
+
+// Country holds the rules of a Wave market. Amounts are in minor units of
+// its currency, see Money.
+type Country struct {
+	Code     string
+	Name     string
+	DialCode string
+	// TrunkPrefix is dialled before national numbers inside the country and
+	// dropped in international format.
+	TrunkPrefix string
+	// MobilePattern matches the national significant number of a mobile line.
+	MobilePattern *regexp.Regexp
+	Currency      string
+	MinAmount     int64
+	MaxAmount     int64
+	// CheckoutFee is charged to merchants on the payments they receive.
+	CheckoutFee Fee
+	// TransferFee is charged to users on the money they send.
+	TransferFee Fee
+}
+
+// Countries lists the markets Wave operates in, keyed by ISO 3166-1 code.
+var Countries = map[string]Country{
+	"SN": {
+		Code:          "SN",
+		Name:          "Senegal",
+		DialCode:      "+221",
+		MobilePattern: regexp.MustCompile(`^(70|71|75|76|77|78)[0-9]{7}$`),
+		Currency:      "XOF",
+		MinAmount:     100,
+		MaxAmount:     1_500_000,
+		CheckoutFee:   Fee{BasisPoints: 100},
+		TransferFee:   Fee{BasisPoints: 100},
+	},
+	"CI": {
+		Code:          "CI",
+		Name:          "Côte d'Ivoire",
+		DialCode:      "+225",
+		MobilePattern: regexp.MustCompile(`^(01|05|07)[0-9]{8}$`),
+		Currency:      "XOF",
+		MinAmount:     100,
+		MaxAmount:     1_500_000,
+		CheckoutFee:   Fee{BasisPoints: 100},
+		TransferFee:   Fee{BasisPoints: 100},
+	},
+	"ML": {
+		Code:          "ML",
+		Name:          "Mali",
+		DialCode:      "+223",
+		MobilePattern: regexp.MustCompile(`^[5-9][0-9]{7}$`),
+		Currency:      "XOF",
+		MinAmount:     100,
+		MaxAmount:     1_000_000,
+		CheckoutFee:   Fee{BasisPoints: 100},
+		TransferFee:   Fee{BasisPoints: 100},
+	},
+	"BF": {
+		Code:          "BF",
+		Name:          "Burkina Faso",
+		DialCode:      "+226",
+		MobilePattern: regexp.MustCompile(`^[05-7][0-9]{7}$`),
+		Currency:      "XOF",
+		MinAmount:     100,
+		MaxAmount:     1_000_000,
+		CheckoutFee:   Fee{BasisPoints: 100},
+		TransferFee:   Fee{BasisPoints: 100},
+	},
+	"GM": {
+		Code:          "GM",
+		Name:          "The Gambia",
+		DialCode:      "+220",
+		MobilePattern: regexp.MustCompile(`^[235679][0-9]{6}$`),
+		Currency:      "GMD",
+		MinAmount:     500,
+		MaxAmount:     10_000_000,
+		CheckoutFee:   Fee{BasisPoints: 100, Min: 100},
+		TransferFee:   Fee{BasisPoints: 100, Min: 100, Max: 50_000},
+	},
+	"UG": {
+		Code:          "UG",
+		Name:          "Uganda",
+		DialCode:      "+256",
+		TrunkPrefix:   "0",
+		MobilePattern: regexp.MustCompile(`^7[0-9]{8}$`),
+		Currency:      "UGX",
+		MinAmount:     500,
+		MaxAmount:     5_000_000,
+		CheckoutFee:   Fee{BasisPoints: 150, Min: 100},
+		TransferFee:   Fee{BasisPoints: 100, Min: 100, Max: 10_000},
+	},
+}
+
+// LookupCountry returns the rules of the market with the ISO 3166-1 code.
+func LookupCountry(code string) (Country, bool) {
+	country, ok := Countries[code]
+	return country, ok
+}
+
+// CountryCodes returns the codes of the supported markets in order.
+func CountryCodes() []string {
+	codes := make([]string, 0, len(Countries))
+	for code := range Countries {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// ParsePhone checks that phone is a mobile number in a supported market and
+// returns it in E.164 format with its country. Numbers without a + dial code
+// are read as national numbers of defaultCountry.
+func ParsePhone(phone, defaultCountry string) (string, Country, bool) {
+	phone = strings.TrimSpace(phone)
+	if strings.HasPrefix(phone, "+") {
+		for _, country := range Countries {
+			if national, ok := strings.CutPrefix(phone, country.DialCode); ok && country.MobilePattern.MatchString(national) {
+				return country.DialCode + national, country, true
+			}
+		}
+		return "", Country{}, false
+	}
+
+	country, ok := Countries[defaultCountry]
+	if !ok {
+		return "", Country{}, false
+	}
+	if country.TrunkPrefix != "" {
+		phone = strings.TrimPrefix(phone, country.TrunkPrefix)
+	}
+	if !country.MobilePattern.MatchString(phone) {
+		return "", Country{}, false
+	}
+	return country.DialCode + phone, country, true
+}
+
+// AllowsAmount reports whether amount is in the currency of the country and
+// within the limits of a single payment there.
+func (c Country) AllowsAmount(amount Money) bool {
+	return amount.Currency == c.Currency && amount.Minor >= c.MinAmount && amount.Minor <= c.MaxAmount
+}
+
+// AmountRange describes the limits of a single payment, for error messages.
+func (c Country) AmountRange() string {
+	return "between " + FormatMinor(c.MinAmount, c.Currency) + " and " + FormatMinor(c.MaxAmount, c.Currency) + " " + c.Currency
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "testing"
+
+func TestFeeOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		fee    Fee
+		amount int64
+		want   int64
+	}{
+		{"one percent", Fee{BasisPoints: 100}, 1500, 15},
+		{"rounds up", Fee{BasisPoints: 100}, 1501, 16},
+		{"rounds up the smallest amount", Fee{BasisPoints: 100}, 1, 1},
+		{"free", Fee{}, 1500, 0},
+		{"minimum", Fee{BasisPoints: 100, Min: 100}, 1250, 100},
+		{"above minimum", Fee{BasisPoints: 100, Min: 100}, 125_000, 1250},
+		{"maximum", Fee{BasisPoints: 100, Min: 100, Max: 50_000}, 10_000_000, 50_000},
+		{"fractional basis points", Fee{BasisPoints: 125}, 10_001, 126},
+		{"whole amount", Fee{BasisPoints: 10_000}, 123_456, 123_456},
+		{"max amount", Fee{BasisPoints: 100}, MaxMinorAmount, MaxMinorAmount / 100},
+		{"max amount at full rate", Fee{BasisPoints: 10_000}, MaxMinorAmount, MaxMinorAmount},
+		{"max amount rounds up", Fee{BasisPoints: 100}, MaxMinorAmount - 1, MaxMinorAmount / 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fee.Of(tt.amount); got != tt.want {
+				t.Errorf("Of(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
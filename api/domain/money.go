@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxMinorAmount bounds any single amount, in minor units, so that sums of
+// amounts and fees stay far from overflowing int64.
+const MaxMinorAmount = 1_000_000_000_000_000
+
+var (
+	ErrInvalidAmount       = errors.New("amount must be a positive decimal number")
+	ErrAmountPrecision     = errors.New("amount has more decimals than its currency allows")
+	ErrAmountOutOfRange    = errors.New("amount is out of range")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+)
+
+// currencyExponents is the number of decimals of each supported currency, as
+// defined by ISO 4217.
+var currencyExponents = map[string]int{
+	"XOF": 0,
+	"XAF": 0,
+	"UGX": 0,
+	"GMD": 2,
+	"USD": 2,
+	"EUR": 2,
+}
+
+var decimalPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+// CurrencyExponent returns the number of decimals of currency.
+func CurrencyExponent(currency string) (int, bool) {
+	exp, ok := currencyExponents[currency]
+	return exp, ok
+}
+
+// Money is an amount in the minor unit of its currency, such as bututs for
+// GMD. Currencies without a minor unit, like XOF, count whole units.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// ParseMoney reads a decimal amount of currency as sent by API clients, such
+// as "1500" for XOF or "12.50" for GMD. Signs, exponents, zero and more
+// decimals than the currency has are rejected.
+func ParseMoney(amount, currency string) (Money, error) {
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, ErrUnsupportedCurrency
+	}
+	if !decimalPattern.MatchString(amount) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	whole, frac, _ := strings.Cut(amount, ".")
+	if len(frac) > exp {
+		return Money{}, ErrAmountPrecision
+	}
+	minor, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exp-len(frac)), 10, 64)
+	if err != nil || minor > MaxMinorAmount {
+		return Money{}, ErrAmountOutOfRange
+	}
+	if minor == 0 {
+		return Money{}, ErrInvalidAmount
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// String formats the amount with the decimals of its currency, the way the
+// API returns amounts.
+func (m Money) String() string {
+	return FormatMinor(m.Minor, m.Currency)
+}
+
+// MinorUnits converts a whole amount of currency to its minor units.
+func MinorUnits(major int64, currency string) int64 {
+	for range currencyExponents[currency] {
+		major *= 10
+	}
+	return major
+}
+
+// FormatMinor formats an amount in minor units of currency as a decimal.
+func FormatMinor(minor int64, currency string) string {
+	exp := currencyExponents[currency]
+	if exp == 0 {
+		return strconv.FormatInt(minor, 10)
+	}
+
+	sign := ""
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	digits := strconv.FormatInt(minor, 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
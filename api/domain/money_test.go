@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  error
+	}{
+		{"1500", "XOF", 1500, nil},
+		{"1500.5", "XOF", 0, ErrAmountPrecision},
+		{"1500.0", "XOF", 0, ErrAmountPrecision},
+		{"12.50", "GMD", 1250, nil},
+		{"12.5", "GMD", 1250, nil},
+		{"12", "GMD", 1200, nil},
+		{"0.01", "GMD", 1, nil},
+		{"12.505", "GMD", 0, ErrAmountPrecision},
+		{"0", "XOF", 0, ErrInvalidAmount},
+		{"0.00", "GMD", 0, ErrInvalidAmount},
+		{"-5", "XOF", 0, ErrInvalidAmount},
+		{"+5", "XOF", 0, ErrInvalidAmount},
+		{"1e3", "XOF", 0, ErrInvalidAmount},
+		{"012", "XOF", 0, ErrInvalidAmount},
+		{"12.", "GMD", 0, ErrInvalidAmount},
+		{"", "XOF", 0, ErrInvalidAmount},
+		{"1500", "ABC", 0, ErrUnsupportedCurrency},
+		{strconv.FormatInt(MaxMinorAmount, 10), "XOF", MaxMinorAmount, nil},
+		{strconv.FormatInt(MaxMinorAmount+1, 10), "XOF", 0, ErrAmountOutOfRange},
+		{"10000000000000.00", "GMD", MaxMinorAmount, nil},
+		{"10000000000000.01", "GMD", 0, ErrAmountOutOfRange},
+		{"99999999999999999999", "XOF", 0, ErrAmountOutOfRange},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			got, err := ParseMoney(tt.amount, tt.currency)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Minor != tt.want || got.Currency != tt.currency) {
+				t.Errorf("ParseMoney = %+v, want %d %s", got, tt.want, tt.currency)
+			}
+		})
+	}
+}
+
+func TestFormatMinor(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{1500, "XOF", "1500"},
+		{-1500, "XOF", "-1500"},
+		{0, "XOF", "0"},
+		{1250, "GMD", "12.50"},
+		{1, "GMD", "0.01"},
+		{10, "GMD", "0.10"},
+		{0, "GMD", "0.00"},
+		{-5, "GMD", "-0.05"},
+		{-1250, "GMD", "-12.50"},
+		{MaxMinorAmount, "GMD", "10000000000000.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+strconv.FormatInt(tt.minor, 10), func(t *testing.T) {
+			if got := FormatMinor(tt.minor, tt.currency); got != tt.want {
+				t.Errorf("FormatMinor = %q, want %q", got, tt.want)
+			}
+			if tt.minor > 0 {
+				back, err := ParseMoney(tt.want, tt.currency)
+				if err != nil || back.Minor != tt.minor {
+					t.Errorf("ParseMoney(%q) = %d, %v, want %d", tt.want, back.Minor, err, tt.minor)
+				}
+			}
+		})
+	}
+}
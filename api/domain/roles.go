@@ -0,0 +1,41 @@
+package domain
+
+import "slices"
+
+// Roles of a business member, from most to least privileged.
+const (
+	RoleOwner     = "owner"
+	RoleAdmin     = "admin"
+	RoleDeveloper = "developer"
+	RoleViewer    = "viewer"
+)
+
+// Permissions checked by the dashboard endpoints.
+const (
+	PermViewAPIKeys    = "api_keys:read"
+	PermManageAPIKeys  = "api_keys:write"
+	PermViewWebhooks   = "webhooks:read"
+	PermManageWebhooks = "webhooks:write"
+	PermManageMembers  = "members:write"
+	PermManageBusiness = "business:write"
+)
+
+// rolePermissions is what each role may do. Owners and admins differ only in
+// that admins cannot change or remove owners.
+var rolePermissions = map[string][]string{
+	RoleOwner:     {PermViewAPIKeys, PermManageAPIKeys, PermViewWebhooks, PermManageWebhooks, PermManageMembers, PermManageBusiness},
+	RoleAdmin:     {PermViewAPIKeys, PermManageAPIKeys, PermViewWebhooks, PermManageWebhooks, PermManageMembers, PermManageBusiness},
+	RoleDeveloper: {PermViewAPIKeys, PermManageAPIKeys, PermViewWebhooks, PermManageWebhooks},
+	RoleViewer:    {PermViewAPIKeys, PermViewWebhooks},
+}
+
+// ValidRole reports whether role is a known member role.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether members with role hold perm.
+func RoleAllows(role, perm string) bool {
+	return slices.Contains(rolePermissions[role], perm)
+}
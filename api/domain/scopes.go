@@ -0,0 +1,36 @@
+package domain
+
+// API key scopes. A key can only call the endpoints of the scopes it holds.
+const (
+	ScopeCheckout            = "checkout"
+	ScopeBalance             = "balance"
+	ScopeB2B                 = "b2b"
+	ScopePayout              = "payout"
+	ScopeAggregatedMerchants = "aggregated-merchants"
+	ScopeWebhooksRead        = "webhooks-read"
+)
+
+type Scope struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Scopes is the catalog of scopes an API key can be granted.
+var Scopes = []Scope{
+	{ScopeCheckout, "Create, read, refund and expire checkout sessions"},
+	{ScopeBalance, "Read the business balance"},
+	{ScopeB2B, "Send and read payments to other businesses"},
+	{ScopePayout, "Send payouts to mobile wallets"},
+	{ScopeAggregatedMerchants, "Manage aggregated merchants"},
+	{ScopeWebhooksRead, "Read webhook configuration"},
+}
+
+// ValidScope reports whether name is in the scope catalog.
+func ValidScope(name string) bool {
+	for _, s := range Scopes {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -58,3 +58,20 @@ func (e *SigningStrategy) Scan(src interface{}) error {
 func (e SigningStrategy) Value() (driver.Value, error) {
 	return string(e), nil
 }
+
+// Environments an API key can be minted for. Sandbox and production data
+// never mix: sessions, payments, balances and webhooks are all scoped to one.
+const (
+	EnvSandbox = "sandbox"
+	EnvProd    = "prod"
+)
+
+// ValidEnv reports whether env is a known environment.
+func ValidEnv(env string) bool {
+	return env == EnvSandbox || env == EnvProd
+}
+
+// APIKeyPrefix returns the prefix of API keys minted for env.
+func APIKeyPrefix(env string) string {
+	return "wave-pool_" + env + "_"
+}
@@ -0,0 +1,45 @@
+package domain
+
+// WalletTier describes the limits applied to a simulated user wallet. The
+// limits of WalletTiers are whole units of any currency; TierFor converts them
+// to the minor units of a wallet's currency.
+type WalletTier struct {
+	Name               string `json:"name"`
+	MaxBalance         int64  `json:"max_balance"`
+	MaxTransfer        int64  `json:"max_transfer"`
+	DailyTransferLimit int64  `json:"daily_transfer_limit"`
+}
+
+const (
+	WalletTierBasic    = "basic"
+	WalletTierVerified = "verified"
+)
+
+// WalletTiers lists the supported tiers, keyed by the users.tier column.
+var WalletTiers = map[string]WalletTier{
+	WalletTierBasic: {
+		Name:               WalletTierBasic,
+		MaxBalance:         200_000,
+		MaxTransfer:        100_000,
+		DailyTransferLimit: 200_000,
+	},
+	WalletTierVerified: {
+		Name:               WalletTierVerified,
+		MaxBalance:         2_000_000,
+		MaxTransfer:        1_000_000,
+		DailyTransferLimit: 1_500_000,
+	},
+}
+
+// TierFor returns the limits of the named tier in minor units of currency,
+// falling back to basic.
+func TierFor(name, currency string) WalletTier {
+	tier, ok := WalletTiers[name]
+	if !ok {
+		tier = WalletTiers[WalletTierBasic]
+	}
+	tier.MaxBalance = MinorUnits(tier.MaxBalance, currency)
+	tier.MaxTransfer = MinorUnits(tier.MaxTransfer, currency)
+	tier.DailyTransferLimit = MinorUnits(tier.DailyTransferLimit, currency)
+	return tier
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "testing"
+
+func TestTierFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		tier     string
+		currency string
+		want     WalletTier
+	}{
+		{"basic XOF", WalletTierBasic, "XOF", WalletTiers[WalletTierBasic]},
+		{"verified XOF", WalletTierVerified, "XOF", WalletTiers[WalletTierVerified]},
+		{"basic GMD", WalletTierBasic, "GMD", WalletTier{
+			Name:               WalletTierBasic,
+			MaxBalance:         20_000_000,
+			MaxTransfer:        10_000_000,
+			DailyTransferLimit: 20_000_000,
+		}},
+		{"verified GMD", WalletTierVerified, "GMD", WalletTier{
+			Name:               WalletTierVerified,
+			MaxBalance:         200_000_000,
+			MaxTransfer:        100_000_000,
+			DailyTransferLimit: 150_000_000,
+		}},
+		{"unknown tier", "gold", "XOF", WalletTiers[WalletTierBasic]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TierFor(tt.tier, tt.currency); got != tt.want {
+				t.Errorf("TierFor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if WalletTiers[WalletTierBasic].MaxBalance != 200_000 {
+		t.Error("TierFor modified WalletTiers")
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AdminMiddleware authenticates support operations with the ADMIN_TOKEN
+// environment variable, sent as a bearer token. Admin endpoints are disabled
+// when ADMIN_TOKEN is not set.
+func (api *API) AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnlockUser lifts a PIN lockout from a user's account.
+// POST /api/v1/admin/users/{user_id}/unlock
+func (api *API) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	if _, err := api.db.GetUserByID(r.Context(), userID); err != nil {
+		if err == pgx.ErrNoRows {
+			returnError(w, domain.LastPaymentError{Code: "user-not-found", Message: "User not found"}, http.StatusNotFound)
+			return
+		}
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to load user"}, http.StatusInternalServerError)
+		return
+	}
+
+	if err := api.unlockUser(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to unlock user", "user_id", userID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to unlock user"}, http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, userID, "account.unlocked", map[string]any{"by": "admin"})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetBusinessFees gives a business its own checkout fee schedule, in basis
+// points with optional minimum and maximum amounts. A null basis_points
+// puts the business back on the fee of its country.
+// PUT /api/v1/admin/businesses/{business_id}/fees
+func (api *API) SetBusinessFees(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		BasisPoints *int64 `json:"basis_points"`
+		Min         string `json:"min"`
+		Max         string `json:"max"`
+	}
+
+	business, err := api.db.GetBusinessByID(r.Context(), r.PathValue("business_id"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+			return
+		}
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to load business"}, http.StatusInternalServerError)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "Invalid request body"}, http.StatusBadRequest)
+		return
+	}
+
+	params := sqlc.UpdateBusinessFeesParams{ID: business.ID}
+	if req.BasisPoints != nil {
+		if *req.BasisPoints < 0 || *req.BasisPoints > 10000 {
+			returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "basis_points must be between 0 and 10000"}, http.StatusBadRequest)
+			return
+		}
+		params.FeeBasisPoints = pgtype.Int4{Int32: int32(*req.BasisPoints), Valid: true}
+		params.FeeMin = pgtype.Int8{Valid: true}
+		params.FeeMax = pgtype.Int8{Valid: true}
+		if req.Min != "" {
+			fee, err := domain.ParseMoney(req.Min, business.Currency)
+			if err != nil {
+				returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "min: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			params.FeeMin.Int64 = fee.Minor
+		}
+		if req.Max != "" {
+			fee, err := domain.ParseMoney(req.Max, business.Currency)
+			if err != nil {
+				returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "max: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			params.FeeMax.Int64 = fee.Minor
+		}
+		if params.FeeMax.Int64 > 0 && params.FeeMax.Int64 < params.FeeMin.Int64 {
+			returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "max must not be less than min"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	updated, err := api.db.UpdateBusinessFees(r.Context(), params)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to update business fees", "business_id", business.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to update business fees"}, http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, updated.OwnerID, "business.fees_updated", map[string]any{"by": "admin", "business_id": updated.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBusinessProfileResponse(updated))
+}
@@ -1,29 +1,64 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
-	"os"
+	"net/netip"
+	"slices"
+	"time"
 
 	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/segmentio/ksuid"
-	"golang.org/x/crypto/argon2"
 )
 
 type CreateAPIKeyRequest struct {
 	Scopes []string `json:"scopes"`
 	Env    string   `json:"env"`
+	// AllowedCIDRs restricts the key to clients in these ranges. Bare IP
+	// addresses are accepted as single-host ranges.
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at"`
 }
 
 type CreateAPIKeyResponse struct {
-	ID        string   `json:"id"`
-	SecretKey string   `json:"secret_key"`
-	Prefix    string   `json:"prefix"`
-	Scopes    []string `json:"scopes"`
-	Env       string   `json:"env"`
+	ID           string     `json:"id"`
+	SecretKey    string     `json:"secret_key"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	Env          string     `json:"env"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+}
+
+// apiKeyUsageDays is how many days of request counts ListAPIKeys returns.
+const apiKeyUsageDays = 30
+
+type apiKeyDailyRequests struct {
+	Day      string `json:"day"`
+	Requests int32  `json:"requests"`
+}
+
+type apiKeyResponse struct {
+	sqlc.ListAPIKeysRow
+	DailyRequests []apiKeyDailyRequests `json:"daily_requests"`
+	// Endpoints lists the routes the key's scopes allow it to call.
+	Endpoints []string `json:"endpoints"`
+}
+
+type RollAPIKeyRequest struct {
+	// ExpiresAt is when the old key stops working. The old key is revoked
+	// immediately when it is omitted.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type RollAPIKeyResponse struct {
+	CreateAPIKeyResponse
+	RolledKeyID        string     `json:"rolled_key_id"`
+	RolledKeyExpiresAt *time.Time `json:"rolled_key_expires_at"`
 }
 
 func (api *API) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
@@ -33,84 +68,119 @@ func (api *API) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if req.Env == "" {
+		req.Env = domain.EnvProd
+	}
+	if !domain.ValidEnv(req.Env) {
+		http.Error(w, "env must be sandbox or prod", http.StatusBadRequest)
 		return
 	}
 
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	if len(req.Scopes) == 0 {
+		http.Error(w, "at least one scope is required", http.StatusBadRequest)
 		return
 	}
+	for _, scope := range req.Scopes {
+		if !domain.ValidScope(scope) {
+			http.Error(w, fmt.Sprintf("unknown scope %q", scope), http.StatusBadRequest)
+			return
+		}
+	}
 
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	allowedCIDRs, err := parseAllowedCIDRs(req.AllowedCIDRs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	req.AllowedCIDRs = allowedCIDRs
 
-	// Generate a new API key
-	secretBytes := make([]byte, 86)
-	if _, err := rand.Read(secretBytes); err != nil {
-		http.Error(w, "Failed to generate secret key", http.StatusInternalServerError)
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
 		return
 	}
-	secretKey := base64.RawURLEncoding.EncodeToString(secretBytes)
-
-	// Hash the secret key
-	salt := []byte(os.Getenv("API_SECRET"))
-	hashedSecret := argon2.IDKey([]byte(secretKey), salt, 1, 64*1024, 4, 32)
-	hashedSecretStr := base64.RawURLEncoding.EncodeToString(hashedSecret)
-	log.Println("Hashed Secret for Creation:", hashedSecretStr)
-	keyID := ksuid.New().String()
-	prefix := "wave-pool_prod_"
 
-	params := sqlc.CreateAPIKeyParams{
-		ID:         keyID,
-		BusinessID: business.ID,
-		Prefix:     prefix,
-		KeyHash:    hashedSecretStr,
-		Scopes:     req.Scopes,
-		Env:        req.Env,
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageAPIKeys)
+	if !ok {
+		return
 	}
 
-	apiKey, err := api.db.CreateAPIKey(r.Context(), params)
+	resp, err := mintAPIKey(r.Context(), api.db, business.ID, req)
 	if err != nil {
 		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
 		return
 	}
 
-	resp := CreateAPIKeyResponse{
-		ID:        apiKey.ID,
-		SecretKey: prefix + secretKey,
-		Prefix:    apiKey.Prefix,
-		Scopes:    apiKey.Scopes,
-		Env:       apiKey.Env,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (api *API) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
-	userID, err := GetUserFromContext(r.Context())
+// mintAPIKey creates a key for the business from a validated request and
+// returns it with its secret, which is never shown again.
+func mintAPIKey(ctx context.Context, q sqlc.Querier, businessID string, req CreateAPIKeyRequest) (CreateAPIKeyResponse, error) {
+	secretKey, salt, err := newAPIKeySecret()
 	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
+		return CreateAPIKeyResponse{}, err
+	}
+	keyID := ksuid.New().String()
+	prefix := domain.APIKeyPrefix(req.Env)
+
+	params := sqlc.CreateAPIKeyParams{
+		ID:           keyID,
+		BusinessID:   businessID,
+		Prefix:       prefix,
+		KeyHash:      hashAPIKeySecret(secretKey, salt),
+		Scopes:       req.Scopes,
+		Env:          req.Env,
+		KeySalt:      nullString(salt),
+		AllowedCidrs: req.AllowedCIDRs,
+	}
+	if params.AllowedCidrs == nil {
+		params.AllowedCidrs = []string{}
+	}
+	if req.ExpiresAt != nil {
+		params.ExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
 	}
 
-	user, err := api.db.GetUserByID(r.Context(), userID)
+	apiKey, err := q.CreateAPIKey(ctx, params)
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
+		return CreateAPIKeyResponse{}, err
 	}
 
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	resp := CreateAPIKeyResponse{
+		ID:           apiKey.ID,
+		SecretKey:    prefix + keyID + "_" + secretKey,
+		Prefix:       apiKey.Prefix,
+		Scopes:       apiKey.Scopes,
+		Env:          apiKey.Env,
+		AllowedCIDRs: apiKey.AllowedCidrs,
+	}
+	if apiKey.ExpiresAt.Valid {
+		resp.ExpiresAt = &apiKey.ExpiresAt.Time
+	}
+	return resp, nil
+}
+
+// parseAllowedCIDRs validates an IP allowlist and returns it in canonical form.
+func parseAllowedCIDRs(cidrs []string) ([]string, error) {
+	allowed := make([]string, 0, len(cidrs))
+	for _, c := range cidrs {
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(c)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", c)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		allowed = append(allowed, prefix.Masked().String())
+	}
+	return allowed, nil
+}
+
+func (api *API) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermViewAPIKeys)
+	if !ok {
 		return
 	}
 
@@ -120,33 +190,53 @@ func (api *API) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	since := time.Now().UTC().AddDate(0, 0, -apiKeyUsageDays)
+	usage, err := api.db.ListAPIKeyUsage(r.Context(), sqlc.ListAPIKeyUsageParams{
+		BusinessID: business.ID,
+		Day:        pgtype.Date{Time: since, Valid: true},
+	})
+	if err != nil {
+		http.Error(w, "Failed to list API key usage", http.StatusInternalServerError)
+		return
+	}
+	daily := make(map[string][]apiKeyDailyRequests)
+	for _, u := range usage {
+		daily[u.KeyID] = append(daily[u.KeyID], apiKeyDailyRequests{
+			Day:      u.Day.Time.Format(time.DateOnly),
+			Requests: u.Requests,
+		})
+	}
+
+	routes := api.APIKeyRoutes()
+	resp := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		requests := daily[key.ID]
+		if requests == nil {
+			requests = []apiKeyDailyRequests{}
+		}
+		endpoints := []string{}
+		for _, route := range routes {
+			if slices.Contains(key.Scopes, route.Scope) {
+				endpoints = append(endpoints, route.Pattern)
+			}
+		}
+		resp = append(resp, apiKeyResponse{ListAPIKeysRow: key, DailyRequests: requests, Endpoints: endpoints})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(keys)
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (api *API) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
 	keyID := r.PathValue("key_id")
 	if keyID == "" {
 		http.Error(w, "API Key ID is required", http.StatusBadRequest)
 		return
 	}
 
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageAPIKeys)
+	if !ok {
 		return
 	}
 
@@ -169,6 +259,90 @@ func (api *API) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
 		return
 	}
+	api.forgetAPIKey(r.Context(), keyID)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RollAPIKey replaces a key with a new one with the same scopes and env. The
+// old key keeps working until the optional expires_at, capped at its own
+// expiry, so integrations can switch over without downtime.
+// POST /api/v1/api-keys/{key_id}/roll
+func (api *API) RollAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req RollAPIKeyRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	keyID := r.PathValue("key_id")
+
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageAPIKeys)
+	if !ok {
+		return
+	}
+
+	key, err := api.db.GetAPIKeyByID(r.Context(), keyID)
+	if err != nil || key.BusinessID != business.ID {
+		http.Error(w, "API Key not found", http.StatusNotFound)
+		return
+	}
+
+	if key.Status.String == "revoked" || (key.ExpiresAt.Valid && !key.ExpiresAt.Time.After(time.Now())) {
+		http.Error(w, "API Key is no longer active", http.StatusConflict)
+		return
+	}
+	// Keys from before environments were checked can't be replaced by a key
+	// that works; they need a new key instead.
+	if !domain.ValidEnv(key.Env) {
+		http.Error(w, "API Key has an unknown environment and cannot be rolled", http.StatusConflict)
+		return
+	}
+	// A roll never extends the lifetime of the old key.
+	if req.ExpiresAt != nil && key.ExpiresAt.Valid && req.ExpiresAt.After(key.ExpiresAt.Time) {
+		req.ExpiresAt = &key.ExpiresAt.Time
+	}
+
+	var resp RollAPIKeyResponse
+	err = api.inTx(r.Context(), func(q *sqlc.Queries) error {
+		var err error
+		resp.CreateAPIKeyResponse, err = mintAPIKey(r.Context(), q, business.ID, CreateAPIKeyRequest{
+			Scopes:       key.Scopes,
+			Env:          key.Env,
+			AllowedCIDRs: key.AllowedCidrs,
+		})
+		if err != nil {
+			return err
+		}
+
+		if req.ExpiresAt == nil {
+			return q.RevokeAPIKey(r.Context(), sqlc.RevokeAPIKeyParams{
+				ID:         key.ID,
+				BusinessID: business.ID,
+			})
+		}
+		return q.ExpireAPIKey(r.Context(), sqlc.ExpireAPIKeyParams{
+			ID:         key.ID,
+			BusinessID: business.ID,
+			ExpiresAt:  pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true},
+		})
+	})
+	if err != nil {
+		http.Error(w, "Failed to roll API key", http.StatusInternalServerError)
+		return
+	}
+	api.forgetAPIKey(r.Context(), key.ID)
+
+	resp.RolledKeyID = key.ID
+	resp.RolledKeyExpiresAt = req.ExpiresAt
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
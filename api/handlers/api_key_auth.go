@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/argon2"
+)
+
+// apiKeyCacheTTL bounds how long a key read from the database is trusted
+// before being read again. Revoking a key also drops it from the cache.
+const apiKeyCacheTTL = 30 * time.Second
+
+// errAPIKeyNotFound is returned when no key matches the presented token.
+var errAPIKeyNotFound = errors.New("api key not found")
+
+// errAPIKeyRateLimited is returned when a client tried too many legacy keys.
+var errAPIKeyRateLimited = errors.New("too many api key attempts")
+
+// legacyAPIKeyLength is the length of the secrets of keys minted before key
+// ids existed: 86 random bytes, base64url encoded.
+const legacyAPIKeyLength = 115
+
+// legacyAPIKeyIPRateLimit bounds the legacy key hashes each client IP can
+// make. They cost 64 MiB of memory each, so they are limited before hashing.
+var legacyAPIKeyIPRateLimit = rateLimit{Requests: envInt("LEGACY_API_KEY_IP_RATE_LIMIT", 30), Window: time.Minute}
+
+// isLegacyAPIKey reports whether token is shaped like a legacy key secret.
+func isLegacyAPIKey(token string) bool {
+	if len(token) != legacyAPIKeyLength {
+		return false
+	}
+	for _, c := range token {
+		if !('A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// authenticatedKey is the API key that authenticated a request.
+type authenticatedKey struct {
+	ID           string
+	BusinessID   string
+	BusinessName string
+	Scopes       []string
+	Env          string
+	Status       string
+	ExpiresAt    pgtype.Timestamptz
+	AllowedCIDRs []string
+}
+
+func apiKeyCacheKey(keyID string) string {
+	return "api_key:" + keyID
+}
+
+// newAPIKeySecret returns a random secret and the per-key salt used to hash it.
+func newAPIKeySecret() (secret, salt string, err error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", err
+	}
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secretBytes), base64.RawURLEncoding.EncodeToString(saltBytes), nil
+}
+
+// hashAPIKeySecret hashes a key secret with its salt. Secrets are 256 random
+// bits, so a single HMAC is enough and keeps verification cheap.
+func hashAPIKeySecret(secret, salt string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authenticateAPIKey verifies the part of a bearer token that follows its
+// prefix, presented by the client at ip. Keys are presented as
+// <prefix><key id>_<secret>; tokens shaped like keys minted before key ids
+// existed are looked up by their legacy argon2 hash instead.
+func (api *API) authenticateAPIKey(ctx context.Context, prefix, token, ip string) (authenticatedKey, error) {
+	if keyID, secret, ok := strings.Cut(token, "_"); ok && len(keyID) == 27 {
+		key, err := api.lookupAPIKey(ctx, keyID)
+		if err == nil && key.Prefix == prefix && key.KeySalt.Valid {
+			hash := hashAPIKeySecret(secret, key.KeySalt.String)
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(key.KeyHash)) != 1 {
+				return authenticatedKey{}, errAPIKeyNotFound
+			}
+			return authenticatedKey{
+				ID:           key.ID,
+				BusinessID:   key.BusinessID,
+				BusinessName: key.BusinessName,
+				Scopes:       key.Scopes,
+				Env:          key.Env,
+				Status:       key.Status.String,
+				ExpiresAt:    key.ExpiresAt,
+				AllowedCIDRs: key.AllowedCidrs,
+			}, nil
+		}
+		if err != nil && err != pgx.ErrNoRows {
+			return authenticatedKey{}, err
+		}
+	}
+
+	if !isLegacyAPIKey(token) {
+		return authenticatedKey{}, errAPIKeyNotFound
+	}
+	if !api.rateLimit(ctx, "api_key_legacy:ip:"+ip, legacyAPIKeyIPRateLimit).Allowed {
+		return authenticatedKey{}, errAPIKeyRateLimited
+	}
+
+	salt := []byte(os.Getenv("API_SECRET"))
+	hashedSecret := argon2.IDKey([]byte(token), salt, 1, 64*1024, 4, 32)
+	row, err := api.db.GetAPIKeyByPrefixAndSecret(ctx, sqlc.GetAPIKeyByPrefixAndSecretParams{
+		Prefix:  prefix,
+		KeyHash: base64.RawURLEncoding.EncodeToString(hashedSecret),
+	})
+	if err == pgx.ErrNoRows {
+		return authenticatedKey{}, errAPIKeyNotFound
+	}
+	if err != nil {
+		return authenticatedKey{}, err
+	}
+	return authenticatedKey{
+		ID:           row.ID,
+		BusinessID:   row.BusinessID,
+		BusinessName: row.BusinessName,
+		Scopes:       row.Scopes,
+		Env:          row.Env,
+		Status:       row.Status.String,
+		ExpiresAt:    row.ExpiresAt,
+		AllowedCIDRs: row.AllowedCidrs,
+	}, nil
+}
+
+// lookupAPIKey loads a key by id, going through the Redis cache first.
+func (api *API) lookupAPIKey(ctx context.Context, keyID string) (sqlc.GetAPIKeyForAuthRow, error) {
+	var key sqlc.GetAPIKeyForAuthRow
+	cached, err := api.redis.Get(ctx, apiKeyCacheKey(keyID)).Bytes()
+	if err == nil && json.Unmarshal(cached, &key) == nil {
+		return key, nil
+	}
+	if err != nil && err != redis.Nil {
+		slog.WarnContext(ctx, "Failed to read cached API key", "key_id", keyID, "error", err)
+	}
+
+	key, err = api.db.GetAPIKeyForAuth(ctx, keyID)
+	if err != nil {
+		return sqlc.GetAPIKeyForAuthRow{}, err
+	}
+
+	if raw, err := json.Marshal(key); err == nil {
+		if err := api.redis.Set(ctx, apiKeyCacheKey(keyID), raw, apiKeyCacheTTL).Err(); err != nil {
+			slog.WarnContext(ctx, "Failed to cache API key", "key_id", keyID, "error", err)
+		}
+	}
+	return key, nil
+}
+
+// forgetAPIKey drops a key from the cache so changes to it apply immediately.
+func (api *API) forgetAPIKey(ctx context.Context, keyID string) {
+	if err := api.redis.Del(ctx, apiKeyCacheKey(keyID)).Err(); err != nil {
+		slog.WarnContext(ctx, "Failed to invalidate cached API key", "key_id", keyID, "error", err)
+	}
+}
+
+// forgetBusinessAPIKeys drops every active key of a business from the cache,
+// for changes to the business that cached keys carry.
+func (api *API) forgetBusinessAPIKeys(ctx context.Context, businessID string) {
+	keys, err := api.db.ListAPIKeys(ctx, businessID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to list API keys to invalidate", "business_id", businessID, "error", err)
+		return
+	}
+	for _, key := range keys {
+		api.forgetAPIKey(ctx, key.ID)
+	}
+}
+
+// allowsIP reports whether ip may use the key. Keys without an allowlist
+// accept any client.
+func (k authenticatedKey) allowsIP(ip string) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, c := range k.AllowedCIDRs {
+		if prefix, err := netip.ParsePrefix(c); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/crypto/argon2"
+)
+
+// benchKeyStore serves one salted and one legacy key from memory. Queries the
+// tests and benchmarks don't use panic through the nil embedded Querier.
+type benchKeyStore struct {
+	sqlc.Querier
+	salted sqlc.GetAPIKeyForAuthRow
+	legacy sqlc.GetAPIKeyByPrefixAndSecretRow
+}
+
+func (s *benchKeyStore) GetAPIKeyForAuth(ctx context.Context, id string) (sqlc.GetAPIKeyForAuthRow, error) {
+	if id != s.salted.ID {
+		return sqlc.GetAPIKeyForAuthRow{}, pgx.ErrNoRows
+	}
+	return s.salted, nil
+}
+
+func (s *benchKeyStore) GetAPIKeyByPrefixAndSecret(ctx context.Context, arg sqlc.GetAPIKeyByPrefixAndSecretParams) (sqlc.GetAPIKeyByPrefixAndSecretRow, error) {
+	if arg.Prefix != s.legacy.Prefix || arg.KeyHash != s.legacy.KeyHash {
+		return sqlc.GetAPIKeyByPrefixAndSecretRow{}, pgx.ErrNoRows
+	}
+	return s.legacy, nil
+}
+
+// benchRedis misses every cache read and lets every request through rate
+// limits, so the benchmarks measure hashing and lookups only.
+type benchRedis struct {
+	RedisClient
+}
+
+func (benchRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	return redis.NewStringResult("", redis.Nil)
+}
+
+func (benchRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (benchRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult([]interface{}{int64(1), int64(1), int64(0)}, nil)
+}
+
+// apiKeyFixture is an API serving one salted and one legacy production key,
+// with the tokens that authenticate them.
+type apiKeyFixture struct {
+	api          *API
+	keyID        string
+	secret       string
+	legacySecret string
+}
+
+func newAPIKeyFixture(tb testing.TB) apiKeyFixture {
+	tb.Helper()
+	prefix := domain.APIKeyPrefix(domain.EnvProd)
+
+	secret, salt, err := newAPIKeySecret()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	keyID := ksuid.New().String()
+
+	legacyBytes := make([]byte, 86)
+	if _, err := rand.Read(legacyBytes); err != nil {
+		tb.Fatal(err)
+	}
+	legacySecret := base64.RawURLEncoding.EncodeToString(legacyBytes)
+	legacyHash := argon2.IDKey([]byte(legacySecret), []byte(os.Getenv("API_SECRET")), 1, 64*1024, 4, 32)
+
+	return apiKeyFixture{
+		api: &API{
+			db: &benchKeyStore{
+				salted: sqlc.GetAPIKeyForAuthRow{
+					ID:      keyID,
+					Prefix:  prefix,
+					KeyHash: hashAPIKeySecret(secret, salt),
+					KeySalt: pgtype.Text{String: salt, Valid: true},
+					Env:     domain.EnvProd,
+				},
+				legacy: sqlc.GetAPIKeyByPrefixAndSecretRow{
+					ID:      ksuid.New().String(),
+					Prefix:  prefix,
+					KeyHash: base64.RawURLEncoding.EncodeToString(legacyHash),
+					Env:     domain.EnvProd,
+				},
+			},
+			redis: benchRedis{},
+		},
+		keyID:        keyID,
+		secret:       secret,
+		legacySecret: legacySecret,
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	f := newAPIKeyFixture(t)
+	prod, sandbox := domain.APIKeyPrefix(domain.EnvProd), domain.APIKeyPrefix(domain.EnvSandbox)
+	otherSecret, _, err := newAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		prefix  string
+		token   string
+		wantID  string
+		wantErr error
+	}{
+		{"salted key", prod, f.keyID + "_" + f.secret, f.keyID, nil},
+		{"wrong secret", prod, f.keyID + "_" + otherSecret, "", errAPIKeyNotFound},
+		{"unknown key id", prod, ksuid.New().String() + "_" + f.secret, "", errAPIKeyNotFound},
+		{"other environment", sandbox, f.keyID + "_" + f.secret, "", errAPIKeyNotFound},
+		{"legacy key", prod, f.legacySecret, f.api.db.(*benchKeyStore).legacy.ID, nil},
+		{"legacy key in other environment", sandbox, f.legacySecret, "", errAPIKeyNotFound},
+		{"malformed", prod, "not-a-key", "", errAPIKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := f.api.authenticateAPIKey(context.Background(), tt.prefix, tt.token, "203.0.113.1")
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if key.ID != tt.wantID {
+				t.Errorf("key id = %q, want %q", key.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestIsLegacyAPIKey(t *testing.T) {
+	legacy := strings.Repeat("aZ09-_", legacyAPIKeyLength/6) + strings.Repeat("x", legacyAPIKeyLength%6)
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{"legacy secret", legacy, true},
+		{"too short", legacy[1:], false},
+		{"too long", legacy + "a", false},
+		{"standard base64", legacy[1:] + "+", false},
+		{"padded", legacy[1:] + "=", false},
+		{"salted key", ksuid.New().String() + "_" + legacy[:43], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLegacyAPIKey(tt.token); got != tt.want {
+				t.Errorf("isLegacyAPIKey = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkAuthenticateAPIKey(b *testing.B) {
+	f := newAPIKeyFixture(b)
+	prefix := domain.APIKeyPrefix(domain.EnvProd)
+	ctx := context.Background()
+
+	// baseline is how every key was verified before salted keys: an argon2
+	// hash of the secret with the global salt, then a lookup by hash.
+	b.Run("baseline", func(b *testing.B) {
+		salt := []byte(os.Getenv("API_SECRET"))
+		for b.Loop() {
+			hashed := argon2.IDKey([]byte(f.legacySecret), salt, 1, 64*1024, 4, 32)
+			if _, err := f.api.db.GetAPIKeyByPrefixAndSecret(ctx, sqlc.GetAPIKeyByPrefixAndSecretParams{
+				Prefix:  prefix,
+				KeyHash: base64.RawURLEncoding.EncodeToString(hashed),
+			}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("salted", func(b *testing.B) {
+		token := f.keyID + "_" + f.secret
+		for b.Loop() {
+			if _, err := f.api.authenticateAPIKey(ctx, prefix, token, "203.0.113.1"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("legacy", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := f.api.authenticateAPIKey(ctx, prefix, f.legacySecret, "203.0.113.1"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("malformed", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := f.api.authenticateAPIKey(ctx, prefix, "not-a-key", "203.0.113.1"); err != errAPIKeyNotFound {
+				b.Fatalf("got %v, want errAPIKeyNotFound", err)
+			}
+		}
+	})
+}
+
+func TestAllowsIP(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidrs []string
+		ip    string
+		want  bool
+	}{
+		{"no allowlist", nil, "203.0.113.5", true},
+		{"inside range", []string{"203.0.113.0/24"}, "203.0.113.5", true},
+		{"outside range", []string{"203.0.113.0/24"}, "198.51.100.1", false},
+		{"single address", []string{"198.51.100.1/32"}, "198.51.100.1", true},
+		{"second range", []string{"203.0.113.0/24", "2001:db8::/32"}, "2001:db8::1", true},
+		{"mapped IPv4 client", []string{"203.0.113.0/24"}, "::ffff:203.0.113.5", true},
+		{"unparsable client", []string{"203.0.113.0/24"}, "unknown", false},
+		{"unparsable entry", []string{"garbage"}, "203.0.113.5", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := authenticatedKey{AllowedCIDRs: tt.cidrs}
+			if got := key.allowsIP(tt.ip); got != tt.want {
+				t.Errorf("allowsIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
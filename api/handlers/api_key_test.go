@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// rollKeyStore serves one business, owned by its only member, and one of its
+// keys.
+type rollKeyStore struct {
+	sqlc.Querier
+	key sqlc.ApiKey
+}
+
+func (s *rollKeyStore) GetBusinessMember(ctx context.Context, arg sqlc.GetBusinessMemberParams) (sqlc.BusinessMember, error) {
+	return sqlc.BusinessMember{BusinessID: arg.BusinessID, UserID: arg.UserID, Role: domain.RoleOwner}, nil
+}
+
+func (s *rollKeyStore) GetBusinessByID(ctx context.Context, id string) (sqlc.Business, error) {
+	return sqlc.Business{ID: id}, nil
+}
+
+func (s *rollKeyStore) GetAPIKeyByID(ctx context.Context, id string) (sqlc.ApiKey, error) {
+	if id != s.key.ID {
+		return sqlc.ApiKey{}, pgx.ErrNoRows
+	}
+	return s.key, nil
+}
+
+func rollAPIKey(t *testing.T, key sqlc.ApiKey, body string) (*httptest.ResponseRecorder, *fakeTx) {
+	t.Helper()
+	tx := &fakeTx{}
+	api := &API{db: &rollKeyStore{key: key}, pool: tx, redis: newFakeRedis()}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/api-keys/"+key.ID+"/roll", strings.NewReader(body))
+	r.SetPathValue("key_id", key.ID)
+	r.Header.Set("X-Business-ID", key.BusinessID)
+	r = r.WithContext(context.WithValue(r.Context(), userContextKey, "user-1"))
+	w := httptest.NewRecorder()
+	api.RollAPIKey(w, r)
+	return w, tx
+}
+
+func TestRollAPIKey(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	active := sqlc.ApiKey{
+		ID:         "key-1",
+		BusinessID: "business-1",
+		Env:        domain.EnvProd,
+		Status:     pgtype.Text{String: "active", Valid: true},
+		ExpiresAt:  pgtype.Timestamptz{Time: now.Add(24 * time.Hour), Valid: true},
+	}
+	expiresAt := func(d time.Duration) string {
+		return fmt.Sprintf(`{"expires_at": %q}`, now.Add(d).Format(time.RFC3339))
+	}
+
+	t.Run("revoked key", func(t *testing.T) {
+		key := active
+		key.Status.String = "revoked"
+		w, tx := rollAPIKey(t, key, "")
+		if w.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+		}
+		if len(tx.execs) != 0 {
+			t.Errorf("ran %d statements, want none", len(tx.execs))
+		}
+	})
+
+	t.Run("expired key", func(t *testing.T) {
+		key := active
+		key.ExpiresAt.Time = now.Add(-time.Minute)
+		w, tx := rollAPIKey(t, key, "")
+		if w.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+		}
+		if len(tx.execs) != 0 {
+			t.Errorf("ran %d statements, want none", len(tx.execs))
+		}
+	})
+
+	t.Run("expires_at beyond the old expiry", func(t *testing.T) {
+		w, tx := rollAPIKey(t, active, expiresAt(48*time.Hour))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+		}
+		var resp RollAPIKeyResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.RolledKeyExpiresAt == nil || !resp.RolledKeyExpiresAt.Equal(active.ExpiresAt.Time) {
+			t.Errorf("rolled_key_expires_at = %v, want %v", resp.RolledKeyExpiresAt, active.ExpiresAt.Time)
+		}
+		expired := tx.ran("ExpireAPIKey")
+		if len(expired) != 1 || !tx.committed {
+			t.Fatalf("ExpireAPIKey ran %d times, committed %v", len(expired), tx.committed)
+		}
+		if got := expired[0].args[2].(pgtype.Timestamptz).Time; !got.Equal(active.ExpiresAt.Time) {
+			t.Errorf("old key expires at %v, want %v", got, active.ExpiresAt.Time)
+		}
+	})
+
+	t.Run("expires_at before the old expiry", func(t *testing.T) {
+		w, tx := rollAPIKey(t, active, expiresAt(time.Hour))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+		}
+		expired := tx.ran("ExpireAPIKey")
+		if len(expired) != 1 {
+			t.Fatalf("ExpireAPIKey ran %d times, want 1", len(expired))
+		}
+		if got := expired[0].args[2].(pgtype.Timestamptz).Time; !got.Equal(now.Add(time.Hour)) {
+			t.Errorf("old key expires at %v, want %v", got, now.Add(time.Hour))
+		}
+	})
+
+	t.Run("no expires_at revokes the old key", func(t *testing.T) {
+		w, tx := rollAPIKey(t, active, "")
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+		}
+		if len(tx.ran("RevokeAPIKey")) != 1 || len(tx.ran("ExpireAPIKey")) != 0 {
+			t.Errorf("want the old key revoked, ran %v", tx.execs)
+		}
+	})
+}
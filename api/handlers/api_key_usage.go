@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// apiKeyUsageFlushInterval is how often recorded API key usage is written to
+// the database.
+const apiKeyUsageFlushInterval = 10 * time.Second
+
+type apiKeyUse struct {
+	keyID string
+	ip    string
+	at    time.Time
+}
+
+type apiKeyUsageDay struct {
+	keyID string
+	day   time.Time
+}
+
+// APIKeyUsageRecorder collects API key usage off the request path and writes
+// it to the database in batches.
+type APIKeyUsageRecorder struct {
+	db   sqlc.Querier
+	uses chan apiKeyUse
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewAPIKeyUsageRecorder(db sqlc.Querier) *APIKeyUsageRecorder {
+	r := &APIKeyUsageRecorder{
+		db:   db,
+		uses: make(chan apiKeyUse, 1024),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Close writes the usage recorded so far and stops the recorder. Usage
+// recorded afterwards is dropped.
+func (r *APIKeyUsageRecorder) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// Record notes that a key was used. It never blocks: usage is dropped when
+// the recorder falls behind.
+func (r *APIKeyUsageRecorder) Record(keyID, ip string) {
+	select {
+	case r.uses <- apiKeyUse{keyID: keyID, ip: ip, at: time.Now().UTC()}:
+	default:
+		slog.Warn("Dropping API key usage, recorder is busy", "key_id", keyID)
+	}
+}
+
+func (r *APIKeyUsageRecorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(apiKeyUsageFlushInterval)
+	defer ticker.Stop()
+
+	last := make(map[string]apiKeyUse)
+	counts := make(map[apiKeyUsageDay]int32)
+	add := func(use apiKeyUse) {
+		last[use.keyID] = use
+		day := time.Date(use.at.Year(), use.at.Month(), use.at.Day(), 0, 0, 0, 0, time.UTC)
+		counts[apiKeyUsageDay{use.keyID, day}]++
+	}
+	for {
+		select {
+		case use := <-r.uses:
+			add(use)
+		case <-ticker.C:
+			r.flush(last, counts)
+			clear(last)
+			clear(counts)
+		case <-r.stop:
+			for {
+				select {
+				case use := <-r.uses:
+					add(use)
+				default:
+					r.flush(last, counts)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *APIKeyUsageRecorder) flush(last map[string]apiKeyUse, counts map[apiKeyUsageDay]int32) {
+	ctx := context.Background()
+	for keyID, use := range last {
+		if err := r.db.TouchAPIKey(ctx, sqlc.TouchAPIKeyParams{
+			ID:         keyID,
+			LastUsedAt: pgtype.Timestamptz{Time: use.at, Valid: true},
+			LastUsedIp: nullString(use.ip),
+		}); err != nil {
+			slog.Error("Failed to record API key last use", "key_id", keyID, "error", err)
+		}
+	}
+	for d, n := range counts {
+		if err := r.db.AddAPIKeyUsage(ctx, sqlc.AddAPIKeyUsageParams{
+			KeyID:    d.keyID,
+			Day:      pgtype.Date{Time: d.day, Valid: true},
+			Requests: n,
+		}); err != nil {
+			slog.Error("Failed to record API key usage", "key_id", d.keyID, "error", err)
+		}
+	}
+}
+
+// trustedProxies are the networks of the reverse proxies in front of the API,
+// from the comma separated addresses or CIDRs of TRUSTED_PROXIES.
+var trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+func parseTrustedProxies(list string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, c := range strings.Split(list, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(c)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(c)
+			if addrErr != nil {
+				slog.Warn("Ignoring invalid trusted proxy", "value", c)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client that sent r. Each proxy appends
+// the address it received the request from to X-Forwarded-For, so the client
+// is the rightmost hop that is not one of the trustedProxies. Hops left of it
+// are set by the client and could be spoofed to get around IP allowlists.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		host = hop
+	}
+	return host
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	got := parseTrustedProxies(" 10.0.0.0/8, 192.0.2.7,,not-an-ip, 2001:db8::/32")
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.0.2.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseTrustedProxies = %v, want %v", got, want)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer func(proxies []netip.Prefix) { trustedProxies = proxies }(trustedProxies)
+	trustedProxies = parseTrustedProxies("10.0.0.0/8, 192.0.2.7")
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"direct client", "203.0.113.5:4000", "", "203.0.113.5"},
+		{"untrusted peer ignores XFF", "203.0.113.5:4000", "198.51.100.1", "203.0.113.5"},
+		{"trusted peer", "10.1.2.3:4000", "198.51.100.1", "198.51.100.1"},
+		{"trusted peer without XFF", "10.1.2.3:4000", "", "10.1.2.3"},
+		{"spoofed hop left of client", "10.1.2.3:4000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"multi-hop through proxies", "10.1.2.3:4000", "1.2.3.4, 198.51.100.1, 192.0.2.7, 10.9.9.9", "198.51.100.1"},
+		{"only proxies", "10.1.2.3:4000", "192.0.2.7, 10.9.9.9", "192.0.2.7"},
+		{"mapped IPv4 proxy", "[::ffff:10.1.2.3]:4000", "198.51.100.1", "198.51.100.1"},
+		{"remote without port", "203.0.113.5", "198.51.100.1", "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// usageStore records the API key usage written by an APIKeyUsageRecorder.
+type usageStore struct {
+	sqlc.Querier
+	touched  []sqlc.TouchAPIKeyParams
+	requests map[string]int32
+}
+
+func (s *usageStore) TouchAPIKey(ctx context.Context, arg sqlc.TouchAPIKeyParams) error {
+	s.touched = append(s.touched, arg)
+	return nil
+}
+
+func (s *usageStore) AddAPIKeyUsage(ctx context.Context, arg sqlc.AddAPIKeyUsageParams) error {
+	s.requests[arg.KeyID] += arg.Requests
+	return nil
+}
+
+func TestAPIKeyUsageRecorderCloseFlushes(t *testing.T) {
+	store := &usageStore{requests: make(map[string]int32)}
+	recorder := NewAPIKeyUsageRecorder(store)
+	recorder.Record("key-1", "203.0.113.5")
+	recorder.Record("key-1", "203.0.113.6")
+	recorder.Record("key-2", "198.51.100.1")
+	recorder.Close()
+
+	if len(store.touched) != 2 {
+		t.Fatalf("touched %d keys, want 2", len(store.touched))
+	}
+	for _, touch := range store.touched {
+		if touch.ID == "key-1" && touch.LastUsedIp.String != "203.0.113.6" {
+			t.Errorf("key-1 last used from %q, want 203.0.113.6", touch.LastUsedIp.String)
+		}
+	}
+	if store.requests["key-1"] != 2 || store.requests["key-2"] != 1 {
+		t.Errorf("requests = %v, want key-1: 2, key-2: 1", store.requests)
+	}
+}
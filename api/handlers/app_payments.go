@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+)
+
+type scannedSessionResponse struct {
+	SessionID      string    `json:"session_id"`
+	BusinessID     string    `json:"business_id"`
+	BusinessName   string    `json:"business_name"`
+	Amount         string    `json:"amount"`
+	Currency       string    `json:"currency"`
+	CheckoutStatus string    `json:"checkout_status"`
+	WhenExpires    time.Time `json:"when_expires"`
+}
+
+type appPaymentResponse struct {
+	ID               string                   `json:"id"`
+	Channel          string                   `json:"channel"`
+	SessionID        *string                  `json:"session_id,omitempty"`
+	BusinessID       string                   `json:"business_id"`
+	BusinessName     string                   `json:"business_name"`
+	Amount           string                   `json:"amount"`
+	Currency         string                   `json:"currency"`
+	Status           string                   `json:"status"`
+	PayerMobile      string                   `json:"payer_mobile"`
+	LastPaymentError *domain.LastPaymentError `json:"last_payment_error,omitempty"`
+	WhenCreated      time.Time                `json:"when_created"`
+}
+
+// qrTokenKey signs QR code tokens. Without API_SECRET a throwaway key is
+// generated, which only suits development since printed QR codes stop working
+// on restart and differ between replicas.
+var qrTokenKey = loadQRTokenKey()
+
+func loadQRTokenKey() []byte {
+	if secret := os.Getenv("API_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	slog.Warn("API_SECRET is not set, signing QR codes with a throwaway key")
+	return key
+}
+
+// signQRToken returns the token embedded in a QR code for subject, the
+// prefixed id of the checkout session or merchant it points to.
+func signQRToken(subject string) string {
+	mac := hmac.New(sha256.New, qrTokenKey)
+	mac.Write([]byte(subject))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyQRToken reports whether token was issued for subject.
+func verifyQRToken(subject, token string) bool {
+	return hmac.Equal([]byte(signQRToken(subject)), []byte(token))
+}
+
+// samePhone compares two phone numbers, with or without their dial code.
+func samePhone(a, b string) bool {
+	return phoneKey(a) == phoneKey(b)
+}
+
+// scannedSession loads the open checkout session referenced by a scanned QR code.
+// It writes the error response and returns false when the session can't be paid.
+func (api *API) scannedSession(w http.ResponseWriter, r *http.Request) (sqlc.CheckoutSession, bool) {
+	rawID := r.PathValue("session_id")
+	if rawID == "" || !strings.HasPrefix(rawID, "cos_") {
+		returnError(w, domain.LastPaymentError{Code: "checkout-session-not-found", Message: "Invalid session id"}, http.StatusNotFound)
+		return sqlc.CheckoutSession{}, false
+	}
+	sessionID := strings.TrimPrefix(rawID, "cos_")
+
+	if !verifyQRToken(rawID, r.URL.Query().Get("token")) {
+		returnError(w, domain.LastPaymentError{Code: "invalid-qr-token", Message: "QR code token is invalid"}, http.StatusForbidden)
+		return sqlc.CheckoutSession{}, false
+	}
+
+	session, err := api.db.GetCheckoutSessionByID(r.Context(), sessionID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "checkout-session-not-found", Message: "Checkout session not found"}, http.StatusNotFound)
+		return sqlc.CheckoutSession{}, false
+	}
+
+	if session.ExpiresAt.Time.Before(time.Now()) {
+		returnError(w, domain.LastPaymentError{Code: "checkout-session-expired", Message: "Checkout session has expired"}, http.StatusConflict)
+		return sqlc.CheckoutSession{}, false
+	}
+
+	if session.Status != "open" {
+		returnError(w, domain.LastPaymentError{Code: "checkout-session-not-open", Message: "Checkout session is not open"}, http.StatusConflict)
+		return sqlc.CheckoutSession{}, false
+	}
+
+	return session, true
+}
+
+// payingUser loads the authenticated app user about to pay.
+func (api *API) payingUser(w http.ResponseWriter, r *http.Request) (sqlc.User, bool) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "unauthorized", Message: "Unauthorized"}, http.StatusUnauthorized)
+		return sqlc.User{}, false
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "user-not-found", Message: "User not found"}, http.StatusNotFound)
+		return sqlc.User{}, false
+	}
+
+	if user.Status == "locked" {
+		writeAccountLocked(w, &pinLockedError{})
+		return sqlc.User{}, false
+	}
+
+	if user.Status != "active" {
+		returnError(w, domain.LastPaymentError{
+			Code:    "blocked-account",
+			Message: "The customer used a blocked account to try and pay for the checkout.",
+		}, http.StatusForbidden)
+		return sqlc.User{}, false
+	}
+
+	return user, true
+}
+
+// GetScannedSession returns the merchant and amount of a scanned checkout session
+// so the app can ask the user to confirm the payment.
+// GET /api/v1/payments/{session_id}?token=...
+func (api *API) GetScannedSession(w http.ResponseWriter, r *http.Request) {
+	session, ok := api.scannedSession(w, r)
+	if !ok {
+		return
+	}
+
+	business, err := api.db.GetBusinessByID(r.Context(), session.BusinessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	resp := scannedSessionResponse{
+		SessionID:      "cos_" + session.ID,
+		BusinessID:     business.ID,
+		BusinessName:   business.Name,
+		Amount:         domain.FormatMinor(session.Amount, session.Currency),
+		Currency:       session.Currency,
+		CheckoutStatus: session.Status,
+		WhenExpires:    session.ExpiresAt.Time,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ConfirmScannedSession pays a scanned checkout session as the authenticated user.
+// POST /api/v1/payments/{session_id}/confirm?token=...
+func (api *API) ConfirmScannedSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok := api.payingUser(w, r)
+	if !ok {
+		return
+	}
+
+	session, ok := api.scannedSession(w, r)
+	if !ok {
+		return
+	}
+
+	business, err := api.db.GetBusinessByID(ctx, session.BusinessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	if session.RestrictPayerMobile.Valid && !samePhone(session.RestrictPayerMobile.String, user.Phone) {
+		paymentError := domain.LastPaymentError{
+			Code:    "payer-mobile-mismatch",
+			Message: "The payer's mobile number does not match the restricted mobile number of the checkout session.",
+		}
+		raw, _ := json.Marshal(paymentError)
+		_, _, err := api.failCheckoutPayment(ctx, session, string(raw), &user)
+		var terr *transferError
+		if errors.As(err, &terr) {
+			returnError(w, terr.err, terr.status)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to record payment failure", "session_id", session.ID, "error", err)
+		}
+		returnError(w, paymentError, http.StatusForbidden)
+		return
+	}
+
+	payment, _, err := api.completeCheckoutPayment(ctx, session, &user)
+	var terr *transferError
+	if errors.As(err, &terr) {
+		returnError(w, terr.err, terr.status)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to complete payment", "session_id", session.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to complete payment"}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAppPaymentResponse(payment, business.Name))
+}
+
+// DeclineScannedSession lets the authenticated user refuse a scanned checkout session.
+// POST /api/v1/payments/{session_id}/decline?token=...
+func (api *API) DeclineScannedSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user, ok := api.payingUser(w, r)
+	if !ok {
+		return
+	}
+
+	session, ok := api.scannedSession(w, r)
+	if !ok {
+		return
+	}
+
+	business, err := api.db.GetBusinessByID(ctx, session.BusinessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	paymentError := `{"code": "payment-failure", "message": "The customer declined the payment."}`
+	payment, _, err := api.failCheckoutPayment(ctx, session, paymentError, &user)
+	var terr *transferError
+	if errors.As(err, &terr) {
+		returnError(w, terr.err, terr.status)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to decline payment", "session_id", session.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to decline payment"}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newAppPaymentResponse(payment, business.Name))
+}
+
+func newAppPaymentResponse(payment sqlc.Payment, businessName string) appPaymentResponse {
+	resp := appPaymentResponse{
+		ID:           payment.ID,
+		Channel:      payment.Channel,
+		BusinessID:   payment.BusinessID,
+		BusinessName: businessName,
+		Amount:       domain.FormatMinor(payment.Amount, payment.Currency),
+		Currency:     payment.Currency,
+		Status:       payment.Status,
+		PayerMobile:  payment.PayerMobile.String,
+		WhenCreated:  payment.CreatedAt.Time,
+	}
+	if payment.SessionID.Valid {
+		sessionID := "cos_" + payment.SessionID.String
+		resp.SessionID = &sessionID
+	}
+	if payment.FailureReason.Valid {
+		_ = json.Unmarshal([]byte(payment.FailureReason.String), &resp.LastPaymentError)
+	}
+	return resp
+}
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"os"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/jackc/pgx/v5"
@@ -16,17 +19,26 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// registerUser handles user registration
+var (
+	pinPattern = regexp.MustCompile(`^\d{4}$`)
+	otpPattern = regexp.MustCompile(`^\d{6}$`)
+)
+
+// tokenResponse is returned when a user signs in.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Auth signs an existing user in with their phone and PIN. Unknown phones are
+// rejected; new users go through Register and VerifyRegistration.
+// POST /api/v1/auth
 func (api *API) Auth(w http.ResponseWriter, r *http.Request) {
 	type request struct {
-		Phone string `json:"phone"`
-		Pin   string `json:"pin"`
-	}
-
-	type response struct {
-		AccessToken  string `json:"access_token"`
-		RefreshToken string `json:"refresh_token"`
-		ExpiresIn    int64  `json:"expires_in"`
+		Phone      string `json:"phone"`
+		Pin        string `json:"pin"`
+		DeviceName string `json:"device_name"`
 	}
 
 	var req request
@@ -37,115 +49,268 @@ func (api *API) Auth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate phone number (E.164 format)
-	if matched, _ := regexp.MatchString(`^(\+221)?(77|78|75|71|70|76)[0-9]{7}$`, req.Phone); !matched {
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
 		slog.ErrorContext(r.Context(), "Invalid phone number format", "phone", req.Phone)
 		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
 		return
 	}
+	req.Phone = phone
+
+	if !api.allowRequest(w, r,
+		limitedKey{"auth:phone:" + req.Phone, authPhoneRateLimit},
+		limitedKey{"auth:ip:" + clientIP(r), authIPRateLimit},
+	) {
+		return
+	}
 
 	// Validate PIN (4 digits)
-	if matched, _ := regexp.MatchString(`^\d{4}$`, req.Pin); !matched {
-		slog.ErrorContext(r.Context(), "Invalid PIN format", "pin", req.Pin)
+	if !pinPattern.MatchString(req.Pin) {
 		http.Error(w, "PIN must be 4 digits", http.StatusBadRequest)
 		return
 	}
 
-	// Check if user already exists
-	user, err := api.db.GetUserByPhone(r.Context(), req.Phone)
+	user, err := api.findUserByMobile(r.Context(), req.Phone)
+	if err == pgx.ErrNoRows {
+		slog.InfoContext(r.Context(), "Sign-in for unknown phone", "phone", req.Phone)
+		http.Error(w, "Invalid phone or PIN", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			pinHash, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
-			if err != nil {
-				slog.ErrorContext(r.Context(), "Failed to hash PIN", "error", err)
-				http.Error(w, "Failed to process PIN", http.StatusInternalServerError)
-				return
-			}
-
-			// Create user
-			user, err = api.db.CreateUser(r.Context(), sqlc.CreateUserParams{
-				ID:      ksuid.New().String(),
-				Phone:   req.Phone,
-				PinHash: string(pinHash),
-			})
-			if err != nil {
-				slog.ErrorContext(r.Context(), "Failed to create user", "error", err)
-				http.Error(w, "Failed to create user", http.StatusInternalServerError)
-				return
-			}
-
-			// Create default business for the user
-			_, err = api.db.CreateBusiness(r.Context(), sqlc.CreateBusinessParams{
-				ID:       ksuid.New().String(),
-				Name:     gofakeit.Company(),
-				OwnerID:  user.ID,
-				Country:  "SN", // Default country Senegal
-				Currency: "XOF",
-			})
-			if err != nil {
-				slog.ErrorContext(r.Context(), "Failed to create default business", "error", err)
-				http.Error(w, "Failed to create default business", http.StatusInternalServerError)
-				return
-			}
-		} else {
-
-			slog.ErrorContext(r.Context(), "Failed to check user existence", "error", err)
-			http.Error(w, "Failed to check user existence", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	// Verify PIN
+	if err := api.verifyPIN(r, user, req.Pin); err != nil {
+		var locked *pinLockedError
+		switch {
+		case errors.As(err, &locked):
+			writeAccountLocked(w, locked)
+		case errors.Is(err, errInvalidPIN):
+			slog.ErrorContext(r.Context(), "Invalid PIN", "phone", req.Phone)
+			http.Error(w, "Invalid phone or PIN", http.StatusUnauthorized)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to verify PIN", "phone", req.Phone, "error", err)
+			http.Error(w, "Failed to verify PIN", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	api.writeTokens(w, r, user.ID, req.DeviceName, http.StatusCreated)
+}
+
+// Register starts a registration by sending a one-time code to the phone.
+// POST /api/v1/auth/register
+func (api *API) Register(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phone string `json:"phone"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		return
+	}
+	req.Phone = phone
+
+	if !api.allowRequest(w, r,
+		limitedKey{"otp:phone:" + req.Phone, otpRateLimit},
+		limitedKey{"auth:ip:" + clientIP(r), authIPRateLimit},
+	) {
+		return
+	}
+
+	if _, err := api.findUserByMobile(r.Context(), req.Phone); err == nil {
+		http.Error(w, "Phone number already registered", http.StatusConflict)
+		return
+	} else if err != pgx.ErrNoRows {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := api.sendOTP(r.Context(), "register", req.Phone); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to send verification code", "phone", req.Phone, "error", err)
+		http.Error(w, "Failed to send verification code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"expires_in": int64(otpTTL.Seconds())})
+}
+
+// VerifyRegistration checks the code sent by Register, then creates the user
+// and their business and signs them in.
+// POST /api/v1/auth/register/verify
+func (api *API) VerifyRegistration(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phone        string `json:"phone"`
+		Code         string `json:"code"`
+		Pin          string `json:"pin"`
+		BusinessName string `json:"business_name"`
+		DeviceName   string `json:"device_name"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		return
+	}
+	req.Phone = phone
+	if !otpPattern.MatchString(req.Code) {
+		http.Error(w, "Code must be 6 digits", http.StatusBadRequest)
+		return
+	}
+	if !pinPattern.MatchString(req.Pin) {
+		http.Error(w, "PIN must be 4 digits", http.StatusBadRequest)
+		return
+	}
+
+	if !api.allowRequest(w, r,
+		limitedKey{"auth:phone:" + req.Phone, authPhoneRateLimit},
+		limitedKey{"auth:ip:" + clientIP(r), authIPRateLimit},
+	) {
+		return
+	}
+
+	if err := api.verifyOTP(r.Context(), "register", req.Phone, req.Code); err != nil {
+		if errors.Is(err, errInvalidOTP) {
+			http.Error(w, "Invalid or expired code", http.StatusUnauthorized)
 			return
 		}
+		slog.ErrorContext(r.Context(), "Failed to verify code", "phone", req.Phone, "error", err)
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
 	}
 
-	// Verify PIN
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(req.Pin)); err != nil {
-		slog.ErrorContext(r.Context(), "Invalid PIN", "phone", req.Phone, "error", err)
-		http.Error(w, "Invalid phone or PIN", http.StatusUnauthorized)
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(req.Pin), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to hash PIN", "error", err)
+		http.Error(w, "Failed to process PIN", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate JWT tokens
-	jwtSecret := os.Getenv("API_SECRET")
-	if jwtSecret == "" {
-		jwtSecret = "default-secret"
+	// The business starts out in the market of its owner's phone.
+	_, country, _ := domain.ParsePhone(req.Phone, domain.DefaultCountry)
+
+	businessName := strings.TrimSpace(req.BusinessName)
+	if businessName == "" {
+		businessName = gofakeit.Company()
 	}
 
+	var user sqlc.User
+	err = api.inTx(r.Context(), func(q *sqlc.Queries) error {
+		if _, err := findUserByMobile(r.Context(), q, req.Phone); err == nil {
+			return errPhoneTaken
+		} else if err != pgx.ErrNoRows {
+			return err
+		}
+
+		user, err = q.CreateUser(r.Context(), sqlc.CreateUserParams{
+			ID:      ksuid.New().String(),
+			Phone:   req.Phone,
+			PinHash: string(pinHash),
+		})
+		if err != nil {
+			return err
+		}
+
+		business, err := q.CreateBusiness(r.Context(), sqlc.CreateBusinessParams{
+			ID:       ksuid.New().String(),
+			Name:     businessName,
+			OwnerID:  user.ID,
+			Country:  country.Code,
+			Currency: country.Currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.CreateBusinessMember(r.Context(), sqlc.CreateBusinessMemberParams{
+			BusinessID: business.ID,
+			UserID:     user.ID,
+			Role:       domain.RoleOwner,
+		})
+	})
+	if errors.Is(err, errPhoneTaken) {
+		http.Error(w, "Phone number already registered", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create user", "error", err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
+	}
+
+	api.audit(r, user.ID, "user.registered", map[string]any{"phone": user.Phone})
+	api.writeTokens(w, r, user.ID, req.DeviceName, http.StatusCreated)
+}
+
+var errPhoneTaken = errors.New("phone number already registered")
+
+// writeTokens starts a session on the device and issues its access and
+// refresh tokens.
+func (api *API) writeTokens(w http.ResponseWriter, r *http.Request, userID, deviceName string, status int) {
+	sess := newSession(r, userID, deviceName)
+	resp, err := api.issueTokens(r.Context(), sess, ksuid.New().String())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to issue tokens", "error", err)
+		http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// issueTokens signs a new token pair for the session, with jti as the id of
+// the refresh token, and saves the session.
+func (api *API) issueTokens(ctx context.Context, sess session, jti string) (tokenResponse, error) {
 	// Create access token
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
+	accessTokenString, err := api.jwtKeys.sign(jwt.MapClaims{
+		"typ": tokenTypeAccess,
+		"sub": sess.UserID,
+		"sid": sess.ID,
 		"exp": time.Now().Add(15 * time.Minute).Unix(),
 	})
-	accessTokenString, err := accessToken.SignedString([]byte(jwtSecret))
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to generate access token", "error", err)
-		http.Error(w, "Failed to generate access token", http.StatusInternalServerError)
-		return
+		return tokenResponse{}, err
 	}
 
 	// Create refresh token
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
+	refreshTokenString, err := api.jwtKeys.sign(jwt.MapClaims{
+		"typ": tokenTypeRefresh,
+		"sub": sess.UserID,
+		"sid": sess.ID,
+		"jti": jti,
+		"exp": time.Now().Add(refreshTokenTTL).Unix(),
 	})
-	refreshTokenString, err := refreshToken.SignedString([]byte(jwtSecret))
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to generate refresh token", "error", err)
-		http.Error(w, "Failed to generate refresh token", http.StatusInternalServerError)
-		return
+		return tokenResponse{}, err
 	}
 
 	// Store the refresh token in Redis
-	err = api.redis.Set(r.Context(), "refresh_token:"+refreshTokenString, user.ID, 7*24*time.Hour).Err()
-	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to store refresh token in Redis", "error", err)
-		http.Error(w, "Failed to store refresh token", http.StatusInternalServerError)
-		return
+	if err := api.saveSession(ctx, sess, jti); err != nil {
+		return tokenResponse{}, err
 	}
 
-	resp := response{
+	return tokenResponse{
 		AccessToken:  accessTokenString,
 		RefreshToken: refreshTokenString,
 		ExpiresIn:    900,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(resp)
+	}, nil
 }
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/segmentio/ksuid"
+)
+
+// errInsufficientFunds aborts a transaction when a balance can't cover a debit.
+var errInsufficientFunds = errors.New("insufficient funds")
+
+// parseAmount reads an amount sent by a client in the currency of country
+// and checks it against the limits of a single payment there.
+func parseAmount(amount string, country domain.Country) (domain.Money, error) {
+	money, err := domain.ParseMoney(amount, country.Currency)
+	if err != nil {
+		return domain.Money{}, err
+	}
+	if !country.AllowsAmount(money) {
+		return domain.Money{}, fmt.Errorf("amount must be %s", country.AmountRange())
+	}
+	return money, nil
+}
+
+// GetBalance returns the available and pending balances of the API key's business.
+// GET /v1/balance
+func (api *API) GetBalance(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	businessID, ok := ctx.Value(BusinessIDKey).(string)
+	if !ok {
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "missing business_id in context",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	business, err := api.db.GetBusinessByID(ctx, businessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	zero := domain.FormatMinor(0, business.Currency)
+	resp := domain.BalanceResponse{Amount: zero, Pending: zero, Currency: business.Currency}
+	balance, err := api.db.GetBalance(ctx, sqlc.GetBalanceParams{
+		BusinessID: businessID,
+		Env:        envFromContext(ctx),
+	})
+	if err != nil && err != pgx.ErrNoRows {
+		slog.ErrorContext(ctx, "Failed to get balance", "business_id", businessID, "error", err)
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "Failed to get balance",
+		}, http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		resp = domain.BalanceResponse{
+			Amount:   domain.FormatMinor(balance.Available, balance.Currency),
+			Pending:  domain.FormatMinor(balance.Pending, balance.Currency),
+			Currency: balance.Currency,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListTransactions lists the payments received by the API key's business,
+// most recent first, with the fee withheld from each.
+// GET /v1/transactions?limit=20&offset=0
+func (api *API) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	businessID, ok := ctx.Value(BusinessIDKey).(string)
+	if !ok {
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "missing business_id in context",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	payments, err := api.db.ListBusinessPayments(ctx, sqlc.ListBusinessPaymentsParams{
+		BusinessID: businessID,
+		Env:        envFromContext(ctx),
+		Limit:      int32(limit),
+		Offset:     int32(offset),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list transactions", "business_id", businessID, "error", err)
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "Failed to list transactions",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]domain.BalanceTransaction, 0, len(payments))
+	for _, p := range payments {
+		result = append(result, newBalanceTransaction(p))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
+
+func newBalanceTransaction(p sqlc.Payment) domain.BalanceTransaction {
+	tx := domain.BalanceTransaction{
+		ID:          p.ID,
+		Channel:     p.Channel,
+		Amount:      domain.FormatMinor(p.Amount, p.Currency),
+		Fee:         domain.FormatMinor(p.Fee, p.Currency),
+		NetAmount:   domain.FormatMinor(p.NetAmount, p.Currency),
+		Currency:    p.Currency,
+		PayerMobile: nullableToPtr(p.PayerMobile),
+		WhenCreated: p.CreatedAt.Time,
+	}
+	if p.SessionID.Valid {
+		sessionID := "cos_" + p.SessionID.String
+		tx.CheckoutSessionID = &sessionID
+	}
+	if p.SettlementID.Valid {
+		settlementID := "stl_" + p.SettlementID.String
+		tx.SettlementID = &settlementID
+	}
+	if p.SettlesAt.Valid {
+		tx.WhenAvailable = &p.SettlesAt.Time
+	}
+	if p.SettledAt.Valid {
+		tx.WhenSettled = &p.SettledAt.Time
+	}
+	if p.RefundedAt.Valid {
+		tx.WhenRefunded = &p.RefundedAt.Time
+	}
+	return tx
+}
+
+// CreateB2BPayment pays another business from the available balance of the
+// API key's business. The counterparty is notified with b2b.payment_received
+// or b2b.payment_failed.
+// POST /v1/b2b/payments
+func (api *API) CreateB2BPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req domain.CreateB2BPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: "Invalid JSON body",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+	businessID, ok := ctx.Value(BusinessIDKey).(string)
+	if !ok {
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "missing business_id in context",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	if req.RecipientBusinessID == businessID {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: "A business cannot pay itself",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	sender, err := api.db.GetBusinessByID(ctx, businessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	recipient, err := api.db.GetBusinessByID(ctx, req.RecipientBusinessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "recipient-not-found", Message: "Recipient business not found"}, http.StatusNotFound)
+		return
+	}
+
+	if req.Currency != sender.Currency || req.Currency != recipient.Currency {
+		returnError(w, domain.LastPaymentError{
+			Code:    "currency-mismatch",
+			Message: "Both businesses must hold the payment currency",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, businessCountry(sender))
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	env := envFromContext(ctx)
+	params := sqlc.CreateB2BTransferParams{
+		ID:           ksuid.New().String(),
+		BusinessID:   sender.ID,
+		Counterparty: recipient.ID,
+		Amount:       amount.Minor,
+		Currency:     req.Currency,
+		Status:       "succeeded",
+		Reference:    nullString(req.ClientReference),
+		Env:          env,
+	}
+
+	var transfer sqlc.B2bTransfer
+	err = api.inTx(ctx, func(q *sqlc.Queries) error {
+		if _, err := q.DebitBalance(ctx, sqlc.DebitBalanceParams{
+			Amount:     amount.Minor,
+			BusinessID: sender.ID,
+			Env:        env,
+		}); err != nil {
+			if err == pgx.ErrNoRows {
+				return errInsufficientFunds
+			}
+			return err
+		}
+
+		if _, err := q.CreditBalance(ctx, sqlc.CreditBalanceParams{
+			BusinessID: recipient.ID,
+			Available:  amount.Minor,
+			Currency:   recipient.Currency,
+			Env:        env,
+		}); err != nil {
+			return err
+		}
+
+		var err error
+		transfer, err = q.CreateB2BTransfer(ctx, params)
+		return err
+	})
+
+	eventType := "b2b.payment_received"
+	if errors.Is(err, errInsufficientFunds) {
+		params.Status = "failed"
+		params.FailureReason = nullString(`{"code": "insufficient-funds", "message": "The business did not have enough balance."}`)
+		transfer, err = api.db.CreateB2BTransfer(ctx, params)
+		eventType = "b2b.payment_failed"
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create B2B payment", "business_id", businessID, "error", err)
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "Failed to create B2B payment",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	payment := newB2BPayment(transfer, sender.Name, recipient.Name)
+	api.webhookSender.Send(context.Background(), recipient.ID, env, eventType, transfer.ID, payment)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(payment)
+}
+
+// GetB2BPayment returns a B2B payment sent or received by the API key's business.
+// GET /v1/b2b/payments/{payment_id}
+func (api *API) GetB2BPayment(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	rawID := r.PathValue("payment_id")
+	if !strings.HasPrefix(rawID, "b2b_") {
+		returnError(w, domain.LastPaymentError{
+			Code:    "b2b-payment-not-found",
+			Message: "Invalid payment id",
+		}, http.StatusNotFound)
+		return
+	}
+
+	businessID, ok := ctx.Value(BusinessIDKey).(string)
+	if !ok {
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "missing business_id in context",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	row, err := api.db.GetB2BTransfer(ctx, sqlc.GetB2BTransferParams{
+		ID:         strings.TrimPrefix(rawID, "b2b_"),
+		Env:        envFromContext(ctx),
+		BusinessID: businessID,
+	})
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "b2b-payment-not-found",
+			Message: "B2B payment not found",
+		}, http.StatusNotFound)
+		return
+	}
+
+	payment := newB2BPayment(sqlc.B2bTransfer{
+		ID:            row.ID,
+		BusinessID:    row.BusinessID,
+		Counterparty:  row.Counterparty,
+		Amount:        row.Amount,
+		Currency:      row.Currency,
+		Status:        row.Status,
+		Reference:     row.Reference,
+		CreatedAt:     row.CreatedAt,
+		FailureReason: row.FailureReason,
+	}, row.SenderName, row.CounterpartyName)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(payment)
+}
+
+// ListB2BPayments lists the B2B payments sent or received by the API key's
+// business, most recent first.
+// GET /v1/b2b/payments?limit=20&offset=0
+func (api *API) ListB2BPayments(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	businessID, ok := ctx.Value(BusinessIDKey).(string)
+	if !ok {
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "missing business_id in context",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	rows, err := api.db.ListB2BTransfers(ctx, sqlc.ListB2BTransfersParams{
+		BusinessID: businessID,
+		Env:        envFromContext(ctx),
+		Limit:      int32(limit),
+		Offset:     int32(offset),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list B2B payments", "business_id", businessID, "error", err)
+		returnError(w, domain.LastPaymentError{
+			Code:    "internal-server-error",
+			Message: "Failed to list B2B payments",
+		}, http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]domain.B2BPayment, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, newB2BPayment(sqlc.B2bTransfer{
+			ID:            row.ID,
+			BusinessID:    row.BusinessID,
+			Counterparty:  row.Counterparty,
+			Amount:        row.Amount,
+			Currency:      row.Currency,
+			Status:        row.Status,
+			Reference:     row.Reference,
+			CreatedAt:     row.CreatedAt,
+			FailureReason: row.FailureReason,
+		}, row.SenderName, row.CounterpartyName))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
+
+func newB2BPayment(t sqlc.B2bTransfer, senderName, recipientName string) domain.B2BPayment {
+	payment := domain.B2BPayment{
+		ID:                  "b2b_" + t.ID,
+		SenderBusinessID:    t.BusinessID,
+		SenderName:          senderName,
+		RecipientBusinessID: t.Counterparty,
+		RecipientName:       recipientName,
+		Amount:              domain.FormatMinor(t.Amount, t.Currency),
+		Currency:            t.Currency,
+		ClientReference:     nullableToPtr(t.Reference),
+		Status:              t.Status,
+		WhenCreated:         t.CreatedAt.Time,
+	}
+	if t.FailureReason.Valid {
+		_ = json.Unmarshal([]byte(t.FailureReason.String), &payment.LastPaymentError)
+	}
+	return payment
+}
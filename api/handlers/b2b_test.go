@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+)
+
+// errDatabaseDown stands in for a driver error, whose text must never reach
+// API clients.
+var errDatabaseDown = errors.New("dial tcp 10.0.3.7:5432: connect: connection refused")
+
+// failingB2BStore fails every balance and B2B transfer query.
+type failingB2BStore struct {
+	sqlc.Querier
+}
+
+func (failingB2BStore) GetBusinessByID(ctx context.Context, id string) (sqlc.Business, error) {
+	return sqlc.Business{ID: id, Currency: "XOF"}, nil
+}
+
+func (failingB2BStore) GetBalance(ctx context.Context, arg sqlc.GetBalanceParams) (sqlc.Balance, error) {
+	return sqlc.Balance{}, errDatabaseDown
+}
+
+func (failingB2BStore) ListB2BTransfers(ctx context.Context, arg sqlc.ListB2BTransfersParams) ([]sqlc.ListB2BTransfersRow, error) {
+	return nil, errDatabaseDown
+}
+
+func TestB2BQueryErrorsAreGeneric(t *testing.T) {
+	api := &API{db: failingB2BStore{}}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+	}{
+		{"GetBalance", api.GetBalance, "/v1/balance"},
+		{"ListB2BPayments", api.ListB2BPayments, "/v1/b2b/payments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			r = r.WithContext(context.WithValue(r.Context(), BusinessIDKey, "business-1"))
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			body := w.Body.String()
+			if strings.Contains(body, "10.0.3.7") || strings.Contains(body, "connection refused") {
+				t.Errorf("body leaks the query error: %s", body)
+			}
+			var resp domain.LastPaymentError
+			if err := json.Unmarshal([]byte(body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != "internal-server-error" {
+				t.Errorf("code = %q, want internal-server-error", resp.Code)
+			}
+		})
+	}
+}
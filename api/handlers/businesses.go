@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/segmentio/ksuid"
+)
+
+// businessCountry returns the market of the business, falling back to the
+// default one for businesses created before countries were checked.
+func businessCountry(business sqlc.Business) domain.Country {
+	country, ok := domain.LookupCountry(business.Country)
+	if !ok {
+		country, _ = domain.LookupCountry(domain.DefaultCountry)
+	}
+	return country
+}
+
+// businessFee returns the fee schedule of the payments the business receives:
+// its own when support negotiated one, the checkout fee of its country
+// otherwise.
+func businessFee(business sqlc.Business) domain.Fee {
+	if business.FeeBasisPoints.Valid {
+		return domain.Fee{
+			BasisPoints: int64(business.FeeBasisPoints.Int32),
+			Min:         business.FeeMin.Int64,
+			Max:         business.FeeMax.Int64,
+		}
+	}
+	return businessCountry(business).CheckoutFee
+}
+
+// checkoutFee returns the fee withheld from a payment of amount to the
+// business, never more than the payment itself.
+func checkoutFee(business sqlc.Business, amount int64) int64 {
+	return min(businessFee(business).Of(amount), amount)
+}
+
+var errBusinessNotSelected = errors.New("business not selected")
+
+type memberBusinessResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Country   string    `json:"country"`
+	Currency  string    `json:"currency"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// activeBusinessID returns the business a dashboard request acts on: the
+// {business_id} path segment, else the X-Business-ID header, else the user's
+// only business. It returns errBusinessNotSelected when the user belongs to
+// several businesses and the request names none.
+func (api *API) activeBusinessID(r *http.Request, userID string) (string, error) {
+	if id := cmp.Or(r.PathValue("business_id"), r.Header.Get("X-Business-ID")); id != "" {
+		return id, nil
+	}
+
+	businesses, err := api.db.ListBusinessesForUser(r.Context(), userID)
+	if err != nil {
+		return "", err
+	}
+	switch len(businesses) {
+	case 0:
+		return "", pgx.ErrNoRows
+	case 1:
+		return businesses[0].ID, nil
+	}
+	return "", errBusinessNotSelected
+}
+
+// CreateBusiness creates another business owned by the authenticated user.
+// POST /api/v1/businesses
+func (api *API) CreateBusiness(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Name     string `json:"name"`
+		Country  string `json:"country"`
+		Currency string `json:"currency"`
+	}
+
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+		return
+	}
+	country, ok := domain.LookupCountry(cmp.Or(strings.ToUpper(req.Country), domain.DefaultCountry))
+	if !ok {
+		http.Error(w, "country must be one of "+strings.Join(domain.CountryCodes(), ", "), http.StatusBadRequest)
+		return
+	}
+	req.Country = country.Code
+	req.Currency = cmp.Or(strings.ToUpper(req.Currency), country.Currency)
+	if req.Currency != country.Currency {
+		http.Error(w, fmt.Sprintf("currency must be %s in %s", country.Currency, country.Name), http.StatusBadRequest)
+		return
+	}
+
+	var business sqlc.Business
+	err = api.inTx(r.Context(), func(q *sqlc.Queries) error {
+		var err error
+		business, err = q.CreateBusiness(r.Context(), sqlc.CreateBusinessParams{
+			ID:       ksuid.New().String(),
+			Name:     req.Name,
+			OwnerID:  userID,
+			Country:  req.Country,
+			Currency: req.Currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		return q.CreateBusinessMember(r.Context(), sqlc.CreateBusinessMemberParams{
+			BusinessID: business.ID,
+			UserID:     userID,
+			Role:       domain.RoleOwner,
+		})
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create business", "user_id", userID, "error", err)
+		http.Error(w, "Failed to create business", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, userID, "business.created", map[string]any{"business_id": business.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(memberBusinessResponse{
+		ID:        business.ID,
+		Name:      business.Name,
+		Country:   business.Country,
+		Currency:  business.Currency,
+		Role:      domain.RoleOwner,
+		CreatedAt: business.CreatedAt.Time,
+	})
+}
+
+// ListBusinesses returns the businesses the authenticated user belongs to,
+// with their role in each.
+// GET /api/v1/businesses
+func (api *API) ListBusinesses(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	businesses, err := api.db.ListBusinessesForUser(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Failed to list businesses", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": newMemberBusinessResponses(businesses)})
+}
+
+func newMemberBusinessResponses(businesses []sqlc.ListBusinessesForUserRow) []memberBusinessResponse {
+	resp := make([]memberBusinessResponse, 0, len(businesses))
+	for _, b := range businesses {
+		resp = append(resp, memberBusinessResponse{
+			ID:        b.ID,
+			Name:      b.Name,
+			Country:   b.Country,
+			Currency:  b.Currency,
+			Role:      b.Role,
+			CreatedAt: b.CreatedAt.Time,
+		})
+	}
+	return resp
+}
+
+var supportPhonePattern = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+
+type businessProfileResponse struct {
+	ID           string      `json:"id"`
+	Name         string      `json:"name"`
+	LogoURL      *string     `json:"logo_url"`
+	Country      string      `json:"country"`
+	Currency     string      `json:"currency"`
+	SupportEmail *string     `json:"support_email"`
+	SupportPhone *string     `json:"support_phone"`
+	CheckoutFee  feeResponse `json:"checkout_fee"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+func newBusinessProfileResponse(b sqlc.Business) businessProfileResponse {
+	return businessProfileResponse{
+		ID:           b.ID,
+		Name:         b.Name,
+		LogoURL:      nullableToPtr(b.LogoUrl),
+		Country:      b.Country,
+		Currency:     b.Currency,
+		SupportEmail: nullableToPtr(b.SupportEmail),
+		SupportPhone: nullableToPtr(b.SupportPhone),
+		CheckoutFee:  newFeeResponse(businessFee(b), b.Currency),
+		CreatedAt:    b.CreatedAt.Time,
+	}
+}
+
+// GetBusinessProfile returns the branding and contact details of the business
+// shown to its customers.
+// GET /api/v1/business/profile
+func (api *API) GetBusinessProfile(w http.ResponseWriter, r *http.Request) {
+	business, _, ok := api.authorizeBusiness(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBusinessProfileResponse(business))
+}
+
+// errCurrencyHasBalance and errCurrencyHasOpenSessions refuse changes of
+// currency that would leave money or open checkout sessions in the old one.
+var (
+	errCurrencyHasBalance      = errors.New("business holds a balance")
+	errCurrencyHasOpenSessions = errors.New("business has open checkout sessions")
+)
+
+// UpdateBusinessProfile changes the branding and contact details of the
+// business. Omitted fields are left as they are; an empty logo_url,
+// support_email or support_phone clears it. The currency follows the country
+// and cannot change once the business holds a balance or while it has open
+// checkout sessions. Static QR codes price payments in the current currency
+// and need no check.
+// PUT /api/v1/business/profile
+func (api *API) UpdateBusinessProfile(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Name         *string `json:"name"`
+		LogoURL      *string `json:"logo_url"`
+		Country      *string `json:"country"`
+		Currency     *string `json:"currency"`
+		SupportEmail *string `json:"support_email"`
+		SupportPhone *string `json:"support_phone"`
+	}
+
+	business, member, ok := api.authorizeBusiness(w, r, domain.PermManageBusiness)
+	if !ok {
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	params := sqlc.UpdateBusinessProfileParams{
+		ID:           business.ID,
+		Name:         business.Name,
+		LogoUrl:      business.LogoUrl,
+		Country:      business.Country,
+		Currency:     business.Currency,
+		SupportEmail: business.SupportEmail,
+		SupportPhone: business.SupportPhone,
+	}
+	if req.Name != nil {
+		params.Name = strings.TrimSpace(*req.Name)
+		if params.Name == "" || len(params.Name) > 100 {
+			http.Error(w, "name is required and must be at most 100 characters", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.LogoURL != nil {
+		params.LogoUrl = nullString(strings.TrimSpace(*req.LogoURL))
+		if params.LogoUrl.Valid && (len(params.LogoUrl.String) > 2048 || !IsURL(params.LogoUrl.String)) {
+			http.Error(w, "logo_url must be an http or https URL", http.StatusBadRequest)
+			return
+		}
+	}
+	country := businessCountry(business)
+	if req.Country != nil {
+		var ok bool
+		country, ok = domain.LookupCountry(strings.ToUpper(*req.Country))
+		if !ok {
+			http.Error(w, "country must be one of "+strings.Join(domain.CountryCodes(), ", "), http.StatusBadRequest)
+			return
+		}
+		params.Country = country.Code
+		params.Currency = country.Currency
+	}
+	if req.Currency != nil {
+		params.Currency = strings.ToUpper(*req.Currency)
+	}
+	if (req.Country != nil || req.Currency != nil) && params.Currency != country.Currency {
+		http.Error(w, fmt.Sprintf("currency must be %s in %s", country.Currency, country.Name), http.StatusBadRequest)
+		return
+	}
+	if req.SupportEmail != nil {
+		params.SupportEmail = nullString(strings.TrimSpace(*req.SupportEmail))
+		if params.SupportEmail.Valid {
+			addr, err := mail.ParseAddress(params.SupportEmail.String)
+			if err != nil || addr.Address != params.SupportEmail.String || len(addr.Address) > 255 {
+				http.Error(w, "support_email must be an email address", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if req.SupportPhone != nil {
+		params.SupportPhone = nullString(strings.Join(strings.Fields(*req.SupportPhone), ""))
+		if params.SupportPhone.Valid && !supportPhonePattern.MatchString(params.SupportPhone.String) {
+			http.Error(w, "support_phone must be a phone number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var updated sqlc.Business
+	err := api.inTx(r.Context(), func(q *sqlc.Queries) error {
+		// Updating first locks the business row, so the checks below can't
+		// race another change of its profile.
+		var err error
+		updated, err = q.UpdateBusinessProfile(r.Context(), params)
+		if err != nil || updated.Currency == business.Currency {
+			return err
+		}
+
+		hasBalance, err := q.BusinessHasBalance(r.Context(), business.ID)
+		if err != nil {
+			return err
+		}
+		if hasBalance {
+			return errCurrencyHasBalance
+		}
+		hasOpenSessions, err := q.BusinessHasOpenCheckoutSessions(r.Context(), business.ID)
+		if err != nil {
+			return err
+		}
+		if hasOpenSessions {
+			return errCurrencyHasOpenSessions
+		}
+		return nil
+	})
+	if errors.Is(err, errCurrencyHasBalance) {
+		http.Error(w, "The currency cannot change once the business holds a balance", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, errCurrencyHasOpenSessions) {
+		http.Error(w, "The currency cannot change while checkout sessions are open", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to update business", "business_id", business.ID, "error", err)
+		http.Error(w, "Failed to update business", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, member.UserID, "business.updated", map[string]any{"business_id": business.ID})
+
+	// API keys are cached with their business name, which checkout sessions
+	// report as business_name.
+	if updated.Name != business.Name {
+		api.forgetBusinessAPIKeys(r.Context(), business.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newBusinessProfileResponse(updated))
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -34,13 +35,14 @@ func validateISO4217(fl validator.FieldLevel) bool {
 	return re.MatchString(fl.Field().String())
 }
 
-// validateE164 implements validator.Func for E.164 phone numbers.
+// validateE164 implements validator.Func for mobile numbers of the supported
+// countries, with or without their dial code.
 func validateE164(fl validator.FieldLevel) bool {
 	if fl.Field().String() == "" {
 		return true
 	}
-	re := regexp.MustCompile(`^(\+221)?(77|78|75|71|70|76)[0-9]{7}$`)
-	return re.MatchString(fl.Field().String())
+	_, ok := normalizePhone(fl.Field().String())
+	return ok
 }
 
 // CreateCheckoutSession handles the creation of a new checkout session.
@@ -74,19 +76,40 @@ func (api *API) CreateCheckoutSession(w http.ResponseWriter, r *http.Request) {
 	}
 	businessName, _ := ctx.Value(BusinessNameKey).(string)
 
+	business, err := api.db.GetBusinessByID(ctx, businessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+	if req.Currency != business.Currency {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: "currency must be " + business.Currency,
+		}, http.StatusBadRequest)
+		return
+	}
+	amount, err := parseAmount(req.Amount, businessCountry(business))
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
 	// ---------- 4. Construire la session ----------
 	sessionID := ksuid.New().String()
 	now := time.Now().UTC()
 	expiresAt := now.Add(30 * time.Minute)
 
 	baseLaunch := cmp.Or(os.Getenv("WAVE_LAUNCH_URL"), "http://localhost:"+cmp.Or(os.Getenv("PORT"), "8080"))
-	waveLaunchURL := fmt.Sprintf("%s/c/cos_%s?a=%s&c=%s&m=%s", baseLaunch, sessionID, req.Amount, req.Currency, businessName)
+	waveLaunchURL := fmt.Sprintf("%s/c/cos_%s?a=%s&c=%s&m=%s", baseLaunch, sessionID, amount, req.Currency, businessName)
 
 	arg := sqlc.CreateCheckoutSessionParams{
 		ID:                   sessionID,
 		BusinessID:           businessID,
 		AggregatedMerchantID: nullString(req.AggregatedMerchantID),
-		Amount:               req.Amount,
+		Amount:               amount.Minor,
 		Currency:             req.Currency,
 		ClientReference:      nullString(req.ClientReference),
 		Status:               "open",
@@ -96,6 +119,7 @@ func (api *API) CreateCheckoutSession(w http.ResponseWriter, r *http.Request) {
 		WaveLaunchUrl:        pgtype.Text{String: waveLaunchURL, Valid: true},
 		PaymentStatus:        pgtype.Text{String: "processing", Valid: true},
 		ExpiresAt:            pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		Env:                  envFromContext(ctx),
 	}
 	session, err := api.db.CreateCheckoutSession(ctx, arg)
 	if err != nil {
@@ -109,7 +133,7 @@ func (api *API) CreateCheckoutSession(w http.ResponseWriter, r *http.Request) {
 
 	resp := domain.CheckoutSessionResponse{
 		ID:                   "cos_" + session.ID,
-		Amount:               session.Amount,
+		Amount:               domain.FormatMinor(session.Amount, session.Currency),
 		CheckoutStatus:       session.Status,
 		ClientReference:      nullableToPtr(session.ClientReference),
 		Currency:             session.Currency,
@@ -156,6 +180,7 @@ func (api *API) GetCheckoutSession(w http.ResponseWriter, r *http.Request) {
 	session, err := api.db.GetCheckoutSession(ctx, sqlc.GetCheckoutSessionParams{
 		ID:         sessionID,
 		BusinessID: businessID,
+		Env:        envFromContext(ctx),
 	})
 	if err != nil {
 		returnError(w, domain.LastPaymentError{
@@ -169,7 +194,7 @@ func (api *API) GetCheckoutSession(w http.ResponseWriter, r *http.Request) {
 
 	resp := domain.CheckoutSessionResponse{
 		ID:                   "cos_" + session.ID,
-		Amount:               session.Amount,
+		Amount:               domain.FormatMinor(session.Amount, session.Currency),
 		CheckoutStatus:       session.Status,
 		ClientReference:      nullableToPtr(session.ClientReference),
 		Currency:             session.Currency,
@@ -220,6 +245,7 @@ func (api *API) GetCheckoutSessionByTxID(w http.ResponseWriter, r *http.Request)
 	session, err := api.db.GetCheckoutSessionByTxID(ctx, sqlc.GetCheckoutSessionByTxIDParams{
 		TransactionID: pgtype.Text{String: txID, Valid: true},
 		BusinessID:    businessID,
+		Env:           envFromContext(ctx),
 	})
 	if err != nil {
 		returnError(w, domain.LastPaymentError{
@@ -233,7 +259,7 @@ func (api *API) GetCheckoutSessionByTxID(w http.ResponseWriter, r *http.Request)
 
 	resp := domain.CheckoutSessionResponse{
 		ID:                   "cos_" + session.ID,
-		Amount:               session.Amount,
+		Amount:               domain.FormatMinor(session.Amount, session.Currency),
 		CheckoutStatus:       session.Status,
 		ClientReference:      nullableToPtr(session.ClientReference),
 		Currency:             session.Currency,
@@ -285,6 +311,7 @@ func (api *API) SearchCheckoutSessions(w http.ResponseWriter, r *http.Request) {
 	rows, err := api.db.SearchCheckoutSessions(ctx, sqlc.SearchCheckoutSessionsParams{
 		BusinessID:      businessID,
 		ClientReference: pgtype.Text{String: ref, Valid: true},
+		Env:             envFromContext(ctx),
 	})
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -306,7 +333,7 @@ func (api *API) SearchCheckoutSessions(w http.ResponseWriter, r *http.Request) {
 	for _, s := range rows {
 		res := domain.CheckoutSessionResponse{
 			ID:                   "cos_" + s.ID,
-			Amount:               s.Amount,
+			Amount:               domain.FormatMinor(s.Amount, s.Currency),
 			CheckoutStatus:       s.Status,
 			ClientReference:      nullableToPtr(s.ClientReference),
 			Currency:             s.Currency,
@@ -362,6 +389,7 @@ func (api *API) RefundCheckoutSession(w http.ResponseWriter, r *http.Request) {
 	session, err := api.db.GetCheckoutSession(ctx, sqlc.GetCheckoutSessionParams{
 		ID:         sessionID,
 		BusinessID: businessID,
+		Env:        envFromContext(ctx),
 	})
 	if err != nil {
 		returnError(w, domain.LastPaymentError{
@@ -391,11 +419,61 @@ func (api *API) RefundCheckoutSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = api.db.UpdateCheckoutPaymentStatus(ctx, sqlc.UpdateCheckoutPaymentStatusParams{
-		ID:            session.ID,
-		BusinessID:    businessID,
-		PaymentStatus: pgtype.Text{String: "cancelled", Valid: true},
+	// The customer gets the full amount back and the fee is waived, so the
+	// business returns what it was credited, from its pending balance until
+	// the payment is settled. App users are refunded to their wallet.
+	err = api.inTx(ctx, func(q *sqlc.Queries) error {
+		payment, err := q.RefundSessionPayment(ctx, nullString(session.ID))
+		if err != nil {
+			return fmt.Errorf("refund payment: %w", err)
+		}
+
+		if payment.SettledAt.Valid {
+			_, err = q.DebitBalance(ctx, sqlc.DebitBalanceParams{
+				Amount:     payment.NetAmount,
+				BusinessID: businessID,
+				Env:        session.Env,
+			})
+		} else {
+			_, err = q.DebitPendingBalance(ctx, sqlc.DebitPendingBalanceParams{
+				Amount:     payment.NetAmount,
+				BusinessID: businessID,
+				Env:        session.Env,
+			})
+		}
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return errInsufficientFunds
+			}
+			return err
+		}
+
+		if payment.UserID.Valid {
+			wallet, err := q.GetWalletForUpdate(ctx, payment.UserID.String)
+			if err != nil {
+				return fmt.Errorf("get wallet: %w", err)
+			}
+			if err := q.UpdateWalletBalance(ctx, sqlc.UpdateWalletBalanceParams{
+				UserID:  wallet.UserID,
+				Balance: wallet.Balance + payment.Amount,
+			}); err != nil {
+				return fmt.Errorf("credit wallet: %w", err)
+			}
+		}
+
+		return q.UpdateCheckoutPaymentStatus(ctx, sqlc.UpdateCheckoutPaymentStatusParams{
+			ID:            session.ID,
+			BusinessID:    businessID,
+			PaymentStatus: pgtype.Text{String: "cancelled", Valid: true},
+		})
 	})
+	if errors.Is(err, errInsufficientFunds) {
+		returnError(w, domain.LastPaymentError{
+			Code:    "checkout-refund-failed",
+			Message: "Insufficient balance to refund this payment",
+		}, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		returnError(w, domain.LastPaymentError{
 			Code:    "internal-server-error",
@@ -434,6 +512,7 @@ func (api *API) ExpireCheckoutSession(w http.ResponseWriter, r *http.Request) {
 	session, err := api.db.GetCheckoutSession(ctx, sqlc.GetCheckoutSessionParams{
 		ID:         sessionID,
 		BusinessID: businessID,
+		Env:        envFromContext(ctx),
 	})
 	if err != nil {
 		returnError(w, domain.LastPaymentError{
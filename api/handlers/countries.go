@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/abdotop/wave-pool/domain"
+)
+
+type feeResponse struct {
+	BasisPoints int64   `json:"basis_points"`
+	Min         string  `json:"min"`
+	Max         *string `json:"max"`
+}
+
+type countryResponse struct {
+	Code        string      `json:"code"`
+	Name        string      `json:"name"`
+	DialCode    string      `json:"dial_code"`
+	Currency    string      `json:"currency"`
+	MinAmount   string      `json:"min_amount"`
+	MaxAmount   string      `json:"max_amount"`
+	CheckoutFee feeResponse `json:"checkout_fee"`
+	TransferFee feeResponse `json:"transfer_fee"`
+}
+
+func newFeeResponse(fee domain.Fee, currency string) feeResponse {
+	resp := feeResponse{BasisPoints: fee.BasisPoints, Min: domain.FormatMinor(fee.Min, currency)}
+	if fee.Max > 0 {
+		max := domain.FormatMinor(fee.Max, currency)
+		resp.Max = &max
+	}
+	return resp
+}
+
+// ListCountries returns the markets businesses and users can sign up in, with
+// their currency, payment limits and fees.
+// GET /api/v1/countries
+func (api *API) ListCountries(w http.ResponseWriter, r *http.Request) {
+	codes := domain.CountryCodes()
+	countries := make([]countryResponse, 0, len(codes))
+	for _, code := range codes {
+		c := domain.Countries[code]
+		countries = append(countries, countryResponse{
+			Code:        c.Code,
+			Name:        c.Name,
+			DialCode:    c.DialCode,
+			Currency:    c.Currency,
+			MinAmount:   domain.FormatMinor(c.MinAmount, c.Currency),
+			MaxAmount:   domain.FormatMinor(c.MaxAmount, c.Currency),
+			CheckoutFee: newFeeResponse(c.CheckoutFee, c.Currency),
+			TransferFee: newFeeResponse(c.TransferFee, c.Currency),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": countries})
+}
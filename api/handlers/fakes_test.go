@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis is an in-memory RedisClient. Keys never expire, and of the Lua
+// scripts it only runs the ones the handlers use: refresh token rotation, and
+// the sliding window limiter, which always allows.
+type fakeRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]bool
+	lists   map[string][]string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		strings: make(map[string]string),
+		sets:    make(map[string]map[string]bool),
+		lists:   make(map[string][]string),
+	}
+}
+
+func fakeRedisValue(value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(value)
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.strings[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v, nil)
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.strings[key] = fakeRedisValue(value)
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		_, s := f.strings[key]
+		_, set := f.sets[key]
+		_, list := f.lists[key]
+		if s || set || list {
+			n++
+		}
+		delete(f.strings, key)
+		delete(f.sets, key)
+		delete(f.lists, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (f *fakeRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch script {
+	case rotateJTIScript:
+		if f.strings[keys[0]] != fakeRedisValue(args[0]) {
+			return redis.NewCmdResult(int64(0), nil)
+		}
+		f.strings[keys[0]] = fakeRedisValue(args[1])
+		return redis.NewCmdResult(int64(1), nil)
+	case slidingWindowScript:
+		window, limit := args[1].(int64), args[2].(int)
+		return redis.NewCmdResult([]interface{}{int64(1), int64(limit - 1), window}, nil)
+	}
+	return redis.NewCmdResult(nil, fmt.Errorf("fakeRedis: unknown script"))
+}
+
+func (f *fakeRedis) Incr(ctx context.Context, key string) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, _ := strconv.ParseInt(f.strings[key], 10, 64)
+	n++
+	f.strings[key] = strconv.FormatInt(n, 10)
+	return redis.NewIntResult(n, nil)
+}
+
+func (f *fakeRedis) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, s := f.strings[key]
+	_, set := f.sets[key]
+	_, list := f.lists[key]
+	return redis.NewBoolResult(s || set || list, nil)
+}
+
+func (f *fakeRedis) LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, v := range values {
+		f.lists[key] = append([]string{fakeRedisValue(v)}, f.lists[key]...)
+	}
+	return redis.NewIntResult(int64(len(f.lists[key])), nil)
+}
+
+// listRange resolves Redis list indexes, which may count from the end.
+func listRange(n int, start, stop int64) (int, int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	start, stop = max(start, 0), min(stop, int64(n)-1)
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+func (f *fakeRedis) LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	from, to := listRange(len(f.lists[key]), start, stop)
+	return redis.NewStringSliceResult(slices.Clone(f.lists[key][from:to]), nil)
+}
+
+func (f *fakeRedis) LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	from, to := listRange(len(f.lists[key]), start, stop)
+	f.lists[key] = f.lists[key][from:to]
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeRedis) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sets[key] == nil {
+		f.sets[key] = make(map[string]bool)
+	}
+	var n int64
+	for _, m := range members {
+		if v := fakeRedisValue(m); !f.sets[key][v] {
+			f.sets[key][v] = true
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (f *fakeRedis) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int64
+	for _, m := range members {
+		if v := fakeRedisValue(m); f.sets[key][v] {
+			delete(f.sets[key], v)
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (f *fakeRedis) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	members := make([]string, 0, len(f.sets[key]))
+	for m := range f.sets[key] {
+		members = append(members, m)
+	}
+	slices.Sort(members)
+	return redis.NewStringSliceResult(members, nil)
+}
+
+// fakeExec is a statement run through a fakeTx.
+type fakeExec struct {
+	sql  string
+	args []any
+}
+
+// fakeTx is a transaction that records the statements run through it. Rows it
+// returns scan without touching their destinations, so queries read back zero
+// values. Methods the handlers don't use panic through the nil embedded Tx.
+type fakeTx struct {
+	pgx.Tx
+	execs     []fakeExec
+	committed bool
+}
+
+func (tx *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return tx, nil
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	tx.execs = append(tx.execs, fakeExec{sql, args})
+	return pgconn.NewCommandTag("UPDATE 1"), nil
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	tx.execs = append(tx.execs, fakeExec{sql, args})
+	return fakeRow{}
+}
+
+// ran returns the statements starting with the given sqlc query name comment.
+func (tx *fakeTx) ran(name string) []fakeExec {
+	var execs []fakeExec
+	for _, e := range tx.execs {
+		if strings.HasPrefix(e.sql, "-- name: "+name+" ") {
+			execs = append(execs, e)
+		}
+	}
+	return execs
+}
+
+type fakeRow struct{}
+
+func (fakeRow) Scan(dest ...any) error {
+	return nil
+}
+
+// fakeUserStore records the account statuses and audit events written by the
+// handlers. Queries it doesn't implement panic through the nil embedded
+// Querier.
+type fakeUserStore struct {
+	sqlc.Querier
+	statuses []string
+	events   []string
+}
+
+func (s *fakeUserStore) UpdateUserStatus(ctx context.Context, arg sqlc.UpdateUserStatusParams) error {
+	s.statuses = append(s.statuses, arg.Status)
+	return nil
+}
+
+func (s *fakeUserStore) CreateAuditLog(ctx context.Context, arg sqlc.CreateAuditLogParams) error {
+	s.events = append(s.events, arg.Event)
+	return nil
+}
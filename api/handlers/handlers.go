@@ -8,6 +8,7 @@ import (
 
 	"github.com/abdotop/wave-pool/db/sqlc"
 	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -16,20 +17,61 @@ type RedisClient interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+}
+
+// TxBeginner starts database transactions, typically a *pgxpool.Pool.
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type API struct {
 	db            sqlc.Querier
+	pool          TxBeginner
 	redis         RedisClient
 	webhookSender *WebhookSender
+	keyUsage      *APIKeyUsageRecorder
+	jwtKeys       *JWTKeys
 }
 
-func NewAPI(db sqlc.Querier, redis RedisClient) *API {
+func NewAPI(db sqlc.Querier, pool TxBeginner, redis RedisClient, jwtKeys *JWTKeys) *API {
 	return &API{
 		db:            db,
+		pool:          pool,
 		redis:         redis,
 		webhookSender: NewWebhookSender(db.(*sqlc.Queries)),
+		keyUsage:      NewAPIKeyUsageRecorder(db),
+		jwtKeys:       jwtKeys,
+	}
+}
+
+// Close writes out the work the API buffers off the request path, such as API
+// key usage. Call it once the server has stopped serving requests.
+func (api *API) Close() {
+	api.keyUsage.Close()
+}
+
+// inTx runs fn with queries bound to a single database transaction, committing
+// only if fn succeeds.
+func (api *API) inTx(ctx context.Context, fn func(q *sqlc.Queries) error) error {
+	tx, err := api.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(sqlc.New(tx)); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
 }
 
 func returnError(w http.ResponseWriter, err domain.LastPaymentError, status int) {
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/segmentio/ksuid"
+)
+
+// JWTKeys holds the Ed25519 keys that sign and verify user tokens. Every key
+// verifies, so a retired key keeps its tokens valid until they expire; only
+// the signing key issues new ones.
+type JWTKeys struct {
+	signingKID string
+	keys       map[string]ed25519.PrivateKey
+}
+
+// LoadJWTKeys reads the PKCS#8 PEM files in dir, each named <kid>.pem, and
+// signs with the key signingKID. signingKID may be empty when dir holds a
+// single key. Without a dir a throwaway key is generated, which only suits
+// development since tokens do not survive a restart.
+func LoadJWTKeys(dir, signingKID string) (*JWTKeys, error) {
+	if dir == "" {
+		if os.Getenv("ENV") == "production" {
+			return nil, errors.New("JWT_KEYS_DIR is required in production")
+		}
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		kid := "dev-" + ksuid.New().String()
+		slog.Warn("JWT_KEYS_DIR is not set, signing tokens with a throwaway key", "kid", kid)
+		return &JWTKeys{signingKID: kid, keys: map[string]ed25519.PrivateKey{kid: key}}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &JWTKeys{signingKID: signingKID, keys: make(map[string]ed25519.PrivateKey, len(files))}
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s: not an Ed25519 key", file)
+		}
+		keys.keys[strings.TrimSuffix(filepath.Base(file), ".pem")] = key
+	}
+
+	if len(keys.keys) == 0 {
+		return nil, fmt.Errorf("no JWT keys in %s", dir)
+	}
+	if keys.signingKID == "" {
+		if len(keys.keys) > 1 {
+			return nil, errors.New("JWT_SIGNING_KID is required when there are several JWT keys")
+		}
+		for kid := range keys.keys {
+			keys.signingKID = kid
+		}
+	}
+	if _, ok := keys.keys[keys.signingKID]; !ok {
+		return nil, fmt.Errorf("no JWT key with kid %q in %s", keys.signingKID, dir)
+	}
+	return keys, nil
+}
+
+// sign issues a token with the signing key, naming it in the kid header.
+func (k *JWTKeys) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.signingKID
+	return token.SignedString(k.keys[k.signingKID])
+}
+
+// parse verifies a token against the key named by its kid header. Tokens
+// signed with any other algorithm are rejected.
+func (k *JWTKeys) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key.Public(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
+}
+
+// JWKS publishes the public keys that verify user tokens, so other services
+// can check them without sharing a secret.
+// GET /.well-known/jwks.json
+func (api *API) JWKS(w http.ResponseWriter, r *http.Request) {
+	type jwk struct {
+		Kty string `json:"kty"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+	}
+
+	kids := make([]string, 0, len(api.jwtKeys.keys))
+	for kid := range api.jwtKeys.keys {
+		kids = append(kids, kid)
+	}
+	slices.Sort(kids)
+
+	keys := make([]jwk, 0, len(kids))
+	for _, kid := range kids {
+		keys = append(keys, jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(api.jwtKeys.keys[kid].Public().(ed25519.PublicKey)),
+			Kid: kid,
+			Use: "sig",
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+}
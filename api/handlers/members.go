@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/segmentio/ksuid"
+)
+
+type businessMemberResponse struct {
+	UserID    string    `json:"user_id"`
+	Phone     string    `json:"phone"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type businessInvitationResponse struct {
+	ID           string    `json:"id"`
+	BusinessID   string    `json:"business_id"`
+	BusinessName string    `json:"business_name,omitempty"`
+	Phone        string    `json:"phone"`
+	Role         string    `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// authorizeBusiness resolves the business a dashboard request acts on and
+// checks that the user's role in it grants perm; an empty perm only requires
+// membership. See activeBusinessID for how the business is picked. It writes
+// the error response and returns false when the request may not proceed.
+func (api *API) authorizeBusiness(w http.ResponseWriter, r *http.Request, perm string) (sqlc.Business, sqlc.BusinessMember, bool) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+
+	businessID, err := api.activeBusinessID(r, userID)
+	if errors.Is(err, errBusinessNotSelected) {
+		http.Error(w, "You belong to several businesses, select one with the X-Business-ID header", http.StatusBadRequest)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+	if err != nil {
+		http.Error(w, "Business not found", http.StatusNotFound)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+
+	member, err := api.db.GetBusinessMember(r.Context(), sqlc.GetBusinessMemberParams{BusinessID: businessID, UserID: userID})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Business not found", http.StatusNotFound)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to load business member", "business_id", businessID, "user_id", userID, "error", err)
+		http.Error(w, "Failed to load business", http.StatusInternalServerError)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+	if perm != "" && !domain.RoleAllows(member.Role, perm) {
+		http.Error(w, fmt.Sprintf("The %s role is not allowed to do this", member.Role), http.StatusForbidden)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+
+	business, err := api.db.GetBusinessByID(r.Context(), businessID)
+	if err != nil {
+		http.Error(w, "Business not found", http.StatusNotFound)
+		return sqlc.Business{}, sqlc.BusinessMember{}, false
+	}
+	return business, member, true
+}
+
+// ListBusinessMembers returns the members of the business.
+// GET /api/v1/business/members
+func (api *API) ListBusinessMembers(w http.ResponseWriter, r *http.Request) {
+	business, _, ok := api.authorizeBusiness(w, r, "")
+	if !ok {
+		return
+	}
+
+	members, err := api.db.ListBusinessMembers(r.Context(), business.ID)
+	if err != nil {
+		http.Error(w, "Failed to list members", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]businessMemberResponse, 0, len(members))
+	for _, m := range members {
+		resp = append(resp, businessMemberResponse{
+			UserID:    m.UserID,
+			Phone:     m.Phone,
+			Role:      m.Role,
+			CreatedAt: m.CreatedAt.Time,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": resp})
+}
+
+// UpdateBusinessMember changes the role of a member. Only owners can grant or
+// take away the owner role, and the business's creator always stays owner.
+// PUT /api/v1/business/members/{user_id}
+func (api *API) UpdateBusinessMember(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Role string `json:"role"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !domain.ValidRole(req.Role) {
+		http.Error(w, "role must be owner, admin, developer or viewer", http.StatusBadRequest)
+		return
+	}
+
+	business, actor, ok := api.authorizeBusiness(w, r, domain.PermManageMembers)
+	if !ok {
+		return
+	}
+
+	target, ok := api.manageableMember(w, r, business, actor, r.PathValue("user_id"))
+	if !ok {
+		return
+	}
+	if req.Role == domain.RoleOwner && actor.Role != domain.RoleOwner {
+		http.Error(w, "Only owners can grant the owner role", http.StatusForbidden)
+		return
+	}
+
+	if err := api.db.UpdateBusinessMemberRole(r.Context(), sqlc.UpdateBusinessMemberRoleParams{
+		BusinessID: business.ID,
+		UserID:     target.UserID,
+		Role:       req.Role,
+	}); err != nil {
+		http.Error(w, "Failed to update member", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, target.UserID, "member.role_changed", map[string]any{
+		"business_id": business.ID,
+		"by":          actor.UserID,
+		"from":        target.Role,
+		"to":          req.Role,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveBusinessMember removes a member from the business. Members can also
+// remove themselves to leave it.
+// DELETE /api/v1/business/members/{user_id}
+func (api *API) RemoveBusinessMember(w http.ResponseWriter, r *http.Request) {
+	business, actor, ok := api.authorizeBusiness(w, r, "")
+	if !ok {
+		return
+	}
+
+	targetID := r.PathValue("user_id")
+	if targetID != actor.UserID && !domain.RoleAllows(actor.Role, domain.PermManageMembers) {
+		http.Error(w, fmt.Sprintf("The %s role is not allowed to do this", actor.Role), http.StatusForbidden)
+		return
+	}
+
+	target, ok := api.manageableMember(w, r, business, actor, targetID)
+	if !ok {
+		return
+	}
+
+	if err := api.db.DeleteBusinessMember(r.Context(), sqlc.DeleteBusinessMemberParams{
+		BusinessID: business.ID,
+		UserID:     target.UserID,
+	}); err != nil {
+		http.Error(w, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, target.UserID, "member.removed", map[string]any{"business_id": business.ID, "by": actor.UserID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// manageableMember loads the member actor wants to change. The business's
+// creator cannot be changed, and only owners can change other owners.
+func (api *API) manageableMember(w http.ResponseWriter, r *http.Request, business sqlc.Business, actor sqlc.BusinessMember, userID string) (sqlc.BusinessMember, bool) {
+	target, err := api.db.GetBusinessMember(r.Context(), sqlc.GetBusinessMemberParams{BusinessID: business.ID, UserID: userID})
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return sqlc.BusinessMember{}, false
+	}
+	if target.UserID == business.OwnerID {
+		http.Error(w, "The business creator cannot be changed or removed", http.StatusConflict)
+		return sqlc.BusinessMember{}, false
+	}
+	if target.Role == domain.RoleOwner && actor.Role != domain.RoleOwner {
+		http.Error(w, "Only owners can change other owners", http.StatusForbidden)
+		return sqlc.BusinessMember{}, false
+	}
+	return target, true
+}
+
+// InviteBusinessMember invites a phone number to join the business with a
+// role and texts them about it. The invitation is accepted from the app once
+// they are signed in.
+// POST /api/v1/business/invitations
+func (api *API) InviteBusinessMember(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phone string `json:"phone"`
+		Role  string `json:"role"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		return
+	}
+	if !domain.ValidRole(req.Role) || req.Role == domain.RoleOwner {
+		http.Error(w, "role must be admin, developer or viewer", http.StatusBadRequest)
+		return
+	}
+
+	business, actor, ok := api.authorizeBusiness(w, r, domain.PermManageMembers)
+	if !ok {
+		return
+	}
+
+	if user, err := api.findUserByMobile(r.Context(), phone); err == nil {
+		if _, err := api.db.GetBusinessMember(r.Context(), sqlc.GetBusinessMemberParams{BusinessID: business.ID, UserID: user.ID}); err == nil {
+			http.Error(w, "This phone is already a member of the business", http.StatusConflict)
+			return
+		}
+	}
+
+	invitation, err := api.db.CreateBusinessInvitation(r.Context(), sqlc.CreateBusinessInvitationParams{
+		ID:         ksuid.New().String(),
+		BusinessID: business.ID,
+		Phone:      phone,
+		Role:       req.Role,
+		InvitedBy:  actor.UserID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create invitation", "business_id", business.ID, "error", err)
+		http.Error(w, "Failed to create invitation", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, actor.UserID, "member.invited", map[string]any{
+		"business_id":   business.ID,
+		"invitation_id": invitation.ID,
+		"phone":         phone,
+		"role":          req.Role,
+	})
+
+	message := fmt.Sprintf("You have been invited to join %s on Wave Pool as %s. Open the app to accept.", business.Name, req.Role)
+	if err := api.sendSMS(r.Context(), phone, message); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to send invitation SMS", "invitation_id", invitation.ID, "error", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newBusinessInvitationResponse(invitation))
+}
+
+// ListBusinessInvitations returns the pending invitations of the business.
+// GET /api/v1/business/invitations
+func (api *API) ListBusinessInvitations(w http.ResponseWriter, r *http.Request) {
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageMembers)
+	if !ok {
+		return
+	}
+
+	invitations, err := api.db.ListBusinessInvitations(r.Context(), business.ID)
+	if err != nil {
+		http.Error(w, "Failed to list invitations", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]businessInvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		resp = append(resp, newBusinessInvitationResponse(inv))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": resp})
+}
+
+// RevokeBusinessInvitation cancels a pending invitation.
+// DELETE /api/v1/business/invitations/{invitation_id}
+func (api *API) RevokeBusinessInvitation(w http.ResponseWriter, r *http.Request) {
+	business, actor, ok := api.authorizeBusiness(w, r, domain.PermManageMembers)
+	if !ok {
+		return
+	}
+
+	invitation, err := api.db.GetBusinessInvitation(r.Context(), r.PathValue("invitation_id"))
+	if err != nil || invitation.BusinessID != business.ID || invitation.Status != "pending" {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	if err := api.db.UpdateBusinessInvitationStatus(r.Context(), sqlc.UpdateBusinessInvitationStatusParams{
+		ID:     invitation.ID,
+		Status: "revoked",
+	}); err != nil {
+		http.Error(w, "Failed to revoke invitation", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, actor.UserID, "member.invitation_revoked", map[string]any{"business_id": business.ID, "invitation_id": invitation.ID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMyInvitations returns the pending invitations sent to the
+// authenticated user's phone.
+// GET /api/v1/me/invitations
+func (api *API) ListMyInvitations(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	invitations, err := api.db.ListInvitationsForPhone(r.Context(), phoneKey(user.Phone))
+	if err != nil {
+		http.Error(w, "Failed to list invitations", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]businessInvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		resp = append(resp, businessInvitationResponse{
+			ID:           inv.ID,
+			BusinessID:   inv.BusinessID,
+			BusinessName: inv.BusinessName,
+			Phone:        inv.Phone,
+			Role:         inv.Role,
+			CreatedAt:    inv.CreatedAt.Time,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": resp})
+}
+
+// AcceptInvitation makes the authenticated user a member of the business
+// that invited their phone.
+// POST /api/v1/me/invitations/{invitation_id}/accept
+func (api *API) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	invitation, err := api.db.GetBusinessInvitation(r.Context(), r.PathValue("invitation_id"))
+	if err != nil || invitation.Status != "pending" || !samePhone(invitation.Phone, user.Phone) {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := api.db.GetBusinessMember(r.Context(), sqlc.GetBusinessMemberParams{BusinessID: invitation.BusinessID, UserID: user.ID}); err == nil {
+		http.Error(w, "You are already a member of this business", http.StatusConflict)
+		return
+	}
+
+	err = api.inTx(r.Context(), func(q *sqlc.Queries) error {
+		if err := q.CreateBusinessMember(r.Context(), sqlc.CreateBusinessMemberParams{
+			BusinessID: invitation.BusinessID,
+			UserID:     user.ID,
+			Role:       invitation.Role,
+		}); err != nil {
+			return err
+		}
+		return q.UpdateBusinessInvitationStatus(r.Context(), sqlc.UpdateBusinessInvitationStatusParams{
+			ID:     invitation.ID,
+			Status: "accepted",
+		})
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to accept invitation", "invitation_id", invitation.ID, "error", err)
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, user.ID, "member.joined", map[string]any{
+		"business_id":   invitation.BusinessID,
+		"invitation_id": invitation.ID,
+		"role":          invitation.Role,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(businessMemberResponse{
+		UserID:    user.ID,
+		Phone:     user.Phone,
+		Role:      invitation.Role,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func newBusinessInvitationResponse(inv sqlc.BusinessInvitation) businessInvitationResponse {
+	return businessInvitationResponse{
+		ID:         inv.ID,
+		BusinessID: inv.BusinessID,
+		Phone:      inv.Phone,
+		Role:       inv.Role,
+		CreatedAt:  inv.CreatedAt.Time,
+	}
+}
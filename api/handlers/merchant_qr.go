@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/segmentio/ksuid"
+	"github.com/skip2/go-qrcode"
+)
+
+type payMerchantRequest struct {
+	Amount string `json:"amount"`
+	Pin    string `json:"pin"`
+}
+
+type scannedMerchantResponse struct {
+	BusinessID   string `json:"business_id"`
+	BusinessName string `json:"business_name"`
+	Currency     string `json:"currency"`
+	Env          string `json:"env"`
+}
+
+// merchantQREnv returns the environment a static QR code pays into, from the
+// env query parameter, defaulting to production.
+func merchantQREnv(r *http.Request) (string, bool) {
+	env := cmp.Or(r.URL.Query().Get("env"), domain.EnvProd)
+	return env, domain.ValidEnv(env)
+}
+
+// merchantQRSubject is what the token of a static QR code signs. Production
+// codes keep the subject they had before codes carried an environment, so
+// printed codes go on working.
+func merchantQRSubject(env, businessID string) string {
+	if env == domain.EnvProd {
+		return "merchant_" + businessID
+	}
+	return "merchant_" + env + "_" + businessID
+}
+
+// MerchantPage renders the static QR code customers scan to pay a business
+// any amount, like a shop's till.
+// GET /m/{business_id}?env=sandbox
+func (api *API) MerchantPage(w http.ResponseWriter, r *http.Request) {
+	env, ok := merchantQREnv(r)
+	if !ok {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "env must be prod or sandbox"}, http.StatusBadRequest)
+		return
+	}
+
+	business, err := api.db.GetBusinessByID(r.Context(), r.PathValue("business_id"))
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return
+	}
+
+	qrContent, err := json.Marshal(domain.PaymentQR{
+		Type:       "merchant",
+		BusinessID: business.ID,
+		Env:        env,
+		Token:      signQRToken(merchantQRSubject(env, business.ID)),
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+	qrCode, err := qrcode.Encode(string(qrContent), qrcode.Medium, 256)
+	if err != nil {
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+	qrCodeBase64 := base64.StdEncoding.EncodeToString(qrCode)
+
+	page := `
+	<!DOCTYPE html>
+	<html lang="en">
+	<head>
+		<meta charset="UTF-8">
+		<meta name="viewport" content="width=device-width, initial-scale=1.0">
+		<title>Wave Pool Merchant</title>
+		<style>
+			body { font-family: sans-serif; display: flex; justify-content: center; align-items: center; height: 100vh; margin: 0; background-color: #f4f7f6; }
+			.container { text-align: center; padding: 40px; border-radius: 10px; background-color: white; box-shadow: 0 4px 8px rgba(0,0,0,0.1); }
+			.logo { width: 150px; margin-bottom: 20px; }
+			.qr-code { margin-top: 20px; margin-bottom: 20px; }
+		</style>
+	</head>
+	<body>
+		<div class="container">
+			<img src="` + businessLogo(business) + `" alt="` + html.EscapeString(business.Name) + ` Logo" class="logo">
+			<h2>Pay ` + html.EscapeString(business.Name) + `</h2>
+			<p>Scan with the Wave Pool app to pay any amount in ` + business.Currency + `</p>
+			<div class="qr-code">
+				<img src="data:image/png;base64,` + qrCodeBase64 + `" alt="QR Code">
+			</div>
+		</div>
+	</body>
+	</html>`
+
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(page))
+}
+
+// scannedMerchant loads the business referenced by a scanned static QR code
+// and the environment the code pays into. It writes the error response and
+// returns false when the code is invalid.
+func (api *API) scannedMerchant(w http.ResponseWriter, r *http.Request) (sqlc.Business, string, bool) {
+	businessID := r.PathValue("business_id")
+	env, ok := merchantQREnv(r)
+	if !ok || !verifyQRToken(merchantQRSubject(env, businessID), r.URL.Query().Get("token")) {
+		returnError(w, domain.LastPaymentError{Code: "invalid-qr-token", Message: "QR code token is invalid"}, http.StatusForbidden)
+		return sqlc.Business{}, "", false
+	}
+
+	business, err := api.db.GetBusinessByID(r.Context(), businessID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "business-not-found", Message: "Business not found"}, http.StatusNotFound)
+		return sqlc.Business{}, "", false
+	}
+	return business, env, true
+}
+
+// GetScannedMerchant returns the business behind a scanned static QR code.
+// GET /api/v1/merchants/{business_id}?token=...&env=...
+func (api *API) GetScannedMerchant(w http.ResponseWriter, r *http.Request) {
+	business, env, ok := api.scannedMerchant(w, r)
+	if !ok {
+		return
+	}
+
+	resp := scannedMerchantResponse{
+		BusinessID:   business.ID,
+		BusinessName: business.Name,
+		Currency:     business.Currency,
+		Env:          env,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// PayMerchant pays an amount chosen by the authenticated user to the business
+// behind a static QR code, and notifies it with merchant.payment_received.
+// POST /api/v1/merchants/{business_id}/pay?token=...&env=...
+func (api *API) PayMerchant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req payMerchantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "Invalid JSON body"}, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := api.payingUser(w, r)
+	if !ok {
+		return
+	}
+
+	business, env, ok := api.scannedMerchant(w, r)
+	if !ok {
+		return
+	}
+
+	amount, err := parseAmount(req.Amount, businessCountry(business))
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	if err := api.verifyPIN(r, user, req.Pin); err != nil {
+		writePINError(w, err)
+		return
+	}
+
+	if err := api.ensureWallet(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "Failed to open wallet", "user_id", user.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to open wallet"}, http.StatusInternalServerError)
+		return
+	}
+
+	fee := checkoutFee(business, amount.Minor)
+
+	var payment sqlc.Payment
+	err = api.inTx(ctx, func(q *sqlc.Queries) error {
+		wallet, err := q.GetWalletForUpdate(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		if wallet.Currency != business.Currency {
+			return &transferError{http.StatusBadRequest, domain.LastPaymentError{
+				Code:    "currency-mismatch",
+				Message: "The merchant does not accept your wallet currency",
+			}}
+		}
+		if wallet.Balance < amount.Minor {
+			return &transferError{http.StatusPaymentRequired, domain.LastPaymentError{
+				Code:    "insufficient-funds",
+				Message: "The user did not have enough account balance.",
+			}}
+		}
+
+		if err := q.UpdateWalletBalance(ctx, sqlc.UpdateWalletBalanceParams{
+			UserID:  user.ID,
+			Balance: wallet.Balance - amount.Minor,
+		}); err != nil {
+			return err
+		}
+
+		if _, err := q.CreditPendingBalance(ctx, sqlc.CreditPendingBalanceParams{
+			BusinessID: business.ID,
+			Pending:    amount.Minor - fee,
+			Currency:   business.Currency,
+			Env:        env,
+		}); err != nil {
+			return err
+		}
+
+		payment, err = q.CreatePayment(ctx, sqlc.CreatePaymentParams{
+			ID:          ksuid.New().String(),
+			BusinessID:  business.ID,
+			Channel:     "merchant",
+			Amount:      amount.Minor,
+			Fee:         fee,
+			NetAmount:   amount.Minor - fee,
+			SettlesAt:   pgtype.Timestamptz{Time: time.Now().Add(settlementDelay), Valid: true},
+			Currency:    business.Currency,
+			Status:      "succeeded",
+			UserID:      nullString(user.ID),
+			PayerMobile: nullString(user.Phone),
+			Env:         env,
+		})
+		return err
+	})
+	if err != nil {
+		var terr *transferError
+		if errors.As(err, &terr) {
+			returnError(w, terr.err, terr.status)
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to pay merchant", "business_id", business.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to pay merchant"}, http.StatusInternalServerError)
+		return
+	}
+
+	api.webhookSender.Send(context.Background(), business.ID, env, "merchant.payment_received", payment.ID, domain.MerchantPayment{
+		ID:           payment.ID,
+		MerchantID:   business.ID,
+		Amount:       domain.FormatMinor(payment.Amount, payment.Currency),
+		Fee:          domain.FormatMinor(payment.Fee, payment.Currency),
+		NetAmount:    domain.FormatMinor(payment.NetAmount, payment.Currency),
+		Currency:     payment.Currency,
+		SenderMobile: user.Phone,
+		WhenCreated:  payment.CreatedAt.Time,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newAppPaymentResponse(payment, business.Name))
+}
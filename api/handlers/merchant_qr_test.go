@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+)
+
+type merchantStore struct {
+	sqlc.Querier
+}
+
+func (merchantStore) GetBusinessByID(ctx context.Context, id string) (sqlc.Business, error) {
+	return sqlc.Business{ID: id, Currency: "XOF"}, nil
+}
+
+func TestScannedMerchantEnv(t *testing.T) {
+	api := &API{db: merchantStore{}}
+	const businessID = "business-1"
+	prodToken := signQRToken(merchantQRSubject(domain.EnvProd, businessID))
+	sandboxToken := signQRToken(merchantQRSubject(domain.EnvSandbox, businessID))
+
+	tests := []struct {
+		name    string
+		env     string
+		token   string
+		wantEnv string
+		wantOK  bool
+	}{
+		{"printed production code", "", signQRToken("merchant_" + businessID), domain.EnvProd, true},
+		{"production", domain.EnvProd, prodToken, domain.EnvProd, true},
+		{"sandbox", domain.EnvSandbox, sandboxToken, domain.EnvSandbox, true},
+		{"sandbox token used in production", domain.EnvProd, sandboxToken, "", false},
+		{"production token used in sandbox", domain.EnvSandbox, prodToken, "", false},
+		{"unknown env", "staging", prodToken, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{"token": {tt.token}}
+			if tt.env != "" {
+				query.Set("env", tt.env)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/merchants/"+businessID+"?"+query.Encode(), nil)
+			r.SetPathValue("business_id", businessID)
+			w := httptest.NewRecorder()
+
+			_, env, ok := api.scannedMerchant(w, r)
+			if ok != tt.wantOK || env != tt.wantEnv {
+				t.Errorf("scannedMerchant = %q, %v, want %q, %v", env, ok, tt.wantEnv, tt.wantOK)
+			}
+			if !ok && w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
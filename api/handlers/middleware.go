@@ -2,26 +2,31 @@ package handlers
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"slices"
 	"strings"
+	"time"
 
-	"github.com/abdotop/wave-pool/db/sqlc"
 	"github.com/abdotop/wave-pool/domain"
-	"github.com/jackc/pgx/v5"
-	"golang.org/x/crypto/argon2"
 )
 
 const (
 	BusinessIDKey   contextKey = "business_id"
 	BusinessNameKey contextKey = "business_name"
 	UserIDKey       contextKey = "user_id"
+	EnvKey          contextKey = "env"
 )
 
+// envFromContext returns the environment of the API key that authenticated
+// the request, defaulting to production.
+func envFromContext(ctx context.Context) string {
+	if env, ok := ctx.Value(EnvKey).(string); ok {
+		return env
+	}
+	return domain.EnvProd
+}
+
 // APIKeyAuthMiddleware validates the API key provided in the Authorization header.
 func (api *API) APIKeyAuthMiddleware(requiredScope string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -45,26 +50,31 @@ func (api *API) APIKeyAuthMiddleware(requiredScope string) func(http.Handler) ht
 			}
 
 			apiKey := parts[1]
-			var secretKey string
-			if !strings.HasPrefix(apiKey, "wave-pool_prod_") {
+			var env, prefix string
+			for _, e := range []string{domain.EnvProd, domain.EnvSandbox} {
+				if strings.HasPrefix(apiKey, domain.APIKeyPrefix(e)) {
+					env, prefix = e, domain.APIKeyPrefix(e)
+					break
+				}
+			}
+			if prefix == "" {
 				returnError(w, domain.LastPaymentError{
 					Code:    "invalid-api-key-prefix",
 					Message: "API key has an invalid prefix",
 				}, http.StatusUnauthorized)
 				return
 			}
-			secretKey = apiKey[len("wave-pool_prod_"):]
-			salt := []byte(os.Getenv("API_SECRET"))
-			hashedSecret := argon2.IDKey([]byte(secretKey), salt, 1, 64*1024, 4, 32)
-			hashedSecretStr := base64.RawURLEncoding.EncodeToString(hashedSecret)
-
-			log.Println("Hashed Secret:", hashedSecretStr)
-			apiKeyRow, err := api.db.GetAPIKeyByPrefixAndSecret(r.Context(), sqlc.GetAPIKeyByPrefixAndSecretParams{
-				Prefix:  "wave-pool_prod_",
-				KeyHash: hashedSecretStr,
-			})
+			ip := clientIP(r)
+			apiKeyRow, err := api.authenticateAPIKey(r.Context(), prefix, apiKey[len(prefix):], ip)
 			if err != nil {
-				if err == pgx.ErrNoRows {
+				if err == errAPIKeyRateLimited {
+					returnError(w, domain.LastPaymentError{
+						Code:    "rate-limit-exceeded",
+						Message: "Too many requests, retry later",
+					}, http.StatusTooManyRequests)
+					return
+				}
+				if err == errAPIKeyNotFound {
 					returnError(w, domain.LastPaymentError{
 						Code:    "no-matching-api-key",
 						Message: fmt.Sprintf("No API key found ending in '%s'", apiKey[len(apiKey)-4:]),
@@ -77,7 +87,7 @@ func (api *API) APIKeyAuthMiddleware(requiredScope string) func(http.Handler) ht
 				return
 			}
 
-			if apiKeyRow.Status.Valid && apiKeyRow.Status.String == "revoked" {
+			if apiKeyRow.Status == "revoked" {
 				returnError(w, domain.LastPaymentError{
 					Code:    "api-key-revoked",
 					Message: "API key has been revoked",
@@ -85,6 +95,22 @@ func (api *API) APIKeyAuthMiddleware(requiredScope string) func(http.Handler) ht
 				return
 			}
 
+			if apiKeyRow.ExpiresAt.Valid && !apiKeyRow.ExpiresAt.Time.After(time.Now()) {
+				returnError(w, domain.LastPaymentError{
+					Code:    "api-key-expired",
+					Message: "API key has expired",
+				}, http.StatusUnauthorized)
+				return
+			}
+
+			if !apiKeyRow.allowsIP(ip) {
+				returnError(w, domain.LastPaymentError{
+					Code:    "api-key-ip-not-allowed",
+					Message: fmt.Sprintf("API key is not allowed to be used from %s", ip),
+				}, http.StatusForbidden)
+				return
+			}
+
 			if !slices.Contains(apiKeyRow.Scopes, requiredScope) {
 				returnError(w, domain.LastPaymentError{
 					Code:    "invalid-wallet",
@@ -93,9 +119,16 @@ func (api *API) APIKeyAuthMiddleware(requiredScope string) func(http.Handler) ht
 				return
 			}
 
+			if !api.allowRequest(w, r, limitedKey{"api_key:" + apiKeyRow.ID, apiKeyRateLimit}) {
+				return
+			}
+
+			api.keyUsage.Record(apiKeyRow.ID, ip)
+
 			// Add business info to the context
 			ctx := context.WithValue(r.Context(), BusinessIDKey, apiKeyRow.BusinessID)
 			ctx = context.WithValue(ctx, BusinessNameKey, apiKeyRow.BusinessName)
+			ctx = context.WithValue(ctx, EnvKey, env)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	otpTTL         = 5 * time.Minute
+	otpMaxAttempts = 5
+	// smsOutboxSize is how many messages are kept per phone in the local outbox.
+	smsOutboxSize = 20
+)
+
+// otpRateLimit bounds how often a code can be sent to the same phone.
+var otpRateLimit = rateLimit{Requests: 3, Window: 10 * time.Minute}
+
+var errInvalidOTP = errors.New("invalid or expired code")
+
+// smsMessage is a text message delivered to the local SMS outbox.
+type smsMessage struct {
+	To     string    `json:"to"`
+	Body   string    `json:"body"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// normalizePhone returns a mobile number of a supported market in E.164
+// format. Numbers without a dial code are read as Senegalese.
+func normalizePhone(phone string) (string, bool) {
+	e164, _, ok := domain.ParsePhone(phone, domain.DefaultCountry)
+	return e164, ok
+}
+
+// phoneKey gives a phone number one form in Redis keys and comparisons.
+func phoneKey(phone string) string {
+	if e164, ok := normalizePhone(phone); ok {
+		return e164
+	}
+	return phone
+}
+
+func otpKey(purpose, phone string) string {
+	return "otp:" + purpose + ":" + phoneKey(phone)
+}
+
+func smsOutboxKey(phone string) string {
+	return "sms_outbox:" + phoneKey(phone)
+}
+
+// sendOTP generates a one-time code for purpose, such as "register", and
+// delivers it to the phone's SMS outbox.
+func (api *API) sendOTP(ctx context.Context, purpose, phone string) error {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	if err := api.redis.Del(ctx, otpKey(purpose, phone)+":attempts").Err(); err != nil {
+		return err
+	}
+	if err := api.redis.Set(ctx, otpKey(purpose, phone), code, otpTTL).Err(); err != nil {
+		return err
+	}
+
+	return api.sendSMS(ctx, phone, fmt.Sprintf("Your Wave Pool code is %s. It expires in %d minutes.", code, int(otpTTL.Minutes())))
+}
+
+// verifyOTP consumes the code sent for purpose. A code is burnt after
+// otpMaxAttempts wrong guesses.
+func (api *API) verifyOTP(ctx context.Context, purpose, phone, code string) error {
+	key := otpKey(purpose, phone)
+	expected, err := api.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return errInvalidOTP
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+		attempts, err := api.redis.Incr(ctx, key+":attempts").Result()
+		if err != nil {
+			return err
+		}
+		api.redis.Expire(ctx, key+":attempts", otpTTL)
+		if attempts >= otpMaxAttempts {
+			api.redis.Del(ctx, key, key+":attempts")
+		}
+		return errInvalidOTP
+	}
+
+	return api.redis.Del(ctx, key, key+":attempts").Err()
+}
+
+// sendSMS delivers a text message to the local outbox, standing in for an SMS
+// gateway.
+func (api *API) sendSMS(ctx context.Context, phone, body string) error {
+	raw, err := json.Marshal(smsMessage{To: phone, Body: body, SentAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	key := smsOutboxKey(phone)
+	if err := api.redis.LPush(ctx, key, raw).Err(); err != nil {
+		return err
+	}
+	api.redis.LTrim(ctx, key, 0, smsOutboxSize-1)
+	api.redis.Expire(ctx, key, 24*time.Hour)
+	return nil
+}
+
+// ListSMSOutbox returns the latest text messages sent to a phone, so codes can
+// be read while developing. It hands out sign-in and PIN reset codes to anyone,
+// so it is only available when DEV_SMS_OUTBOX is true.
+// GET /api/v1/dev/sms/{phone}
+func (api *API) ListSMSOutbox(w http.ResponseWriter, r *http.Request) {
+	if os.Getenv("DEV_SMS_OUTBOX") != "true" {
+		http.NotFound(w, r)
+		return
+	}
+
+	raw, err := api.redis.LRange(r.Context(), smsOutboxKey(r.PathValue("phone")), 0, -1).Result()
+	if err != nil {
+		http.Error(w, "Failed to read SMS outbox", http.StatusInternalServerError)
+		return
+	}
+
+	messages := make([]smsMessage, 0, len(raw))
+	for _, m := range raw {
+		var msg smsMessage
+		if json.Unmarshal([]byte(m), &msg) == nil {
+			messages = append(messages, msg)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": messages})
+}
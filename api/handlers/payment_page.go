@@ -3,13 +3,18 @@ package handlers
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/abdotop/wave-pool/db/sqlc"
 	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/segmentio/ksuid"
 	"github.com/skip2/go-qrcode"
 )
@@ -49,15 +54,23 @@ func (api *API) PaymentPage(w http.ResponseWriter, r *http.Request) {
 	successURL := baseURL + "/c/" + rawID + "/succeed"
 	failURL := baseURL + "/c/" + rawID + "/fail"
 
-	qrContent := fmt.Sprintf(`{"success_url":"%s", "fail_url":"%s"}`, successURL, failURL)
-	qrCode, err := qrcode.Encode(qrContent, qrcode.Medium, 256)
+	qrContent, err := json.Marshal(domain.PaymentQR{
+		Type:      "checkout",
+		SessionID: rawID,
+		Token:     signQRToken(rawID),
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+	qrCode, err := qrcode.Encode(string(qrContent), qrcode.Medium, 256)
 	if err != nil {
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
 	}
 	qrCodeBase64 := base64.StdEncoding.EncodeToString(qrCode)
 
-	html := `
+	page := `
 	<!DOCTYPE html>
 	<html lang="en">
 	<head>
@@ -72,13 +85,14 @@ func (api *API) PaymentPage(w http.ResponseWriter, r *http.Request) {
 			.btn { padding: 10px 20px; border: none; border-radius: 5px; cursor: pointer; color: white; margin: 5px; }
 			.btn-success { background-color: #4CAF50; }
 			.btn-fail { background-color: #f44336; }
+			.support { margin-top: 20px; font-size: 0.9em; color: #666; }
 		</style>
 	</head>
 	<body>
 		<div class="container">
-			<img src="/logo.png" alt="Wave Pool Logo" class="logo">
-			<h2>Payment to ` + business.Name + `</h2>
-			<p>Amount: ` + session.Amount + ` ` + session.Currency + `</p>
+			<img src="` + businessLogo(business) + `" alt="` + html.EscapeString(business.Name) + ` Logo" class="logo">
+			<h2>Payment to ` + html.EscapeString(business.Name) + `</h2>
+			<p>Amount: ` + domain.FormatMinor(session.Amount, session.Currency) + ` ` + session.Currency + `</p>
 			<div class="qr-code">
 				<img src="data:image/png;base64,` + qrCodeBase64 + `" alt="QR Code">
 			</div>
@@ -88,12 +102,39 @@ func (api *API) PaymentPage(w http.ResponseWriter, r *http.Request) {
 			<form action="` + failURL + `" method="post" style="display: inline;">
 				<button type="submit" class="btn btn-fail">Simulate Fail</button>
 			</form>
+			` + businessSupport(business) + `
 		</div>
 	</body>
 	</html>`
 
 	w.Header().Set("Content-Type", "text/html")
-	w.Write([]byte(html))
+	w.Write([]byte(page))
+}
+
+// businessLogo returns the escaped logo URL of the business, falling back to
+// the Wave Pool logo.
+func businessLogo(business sqlc.Business) string {
+	if business.LogoUrl.Valid {
+		return html.EscapeString(business.LogoUrl.String)
+	}
+	return "/logo.png"
+}
+
+// businessSupport renders the support contact of the business, if any.
+func businessSupport(business sqlc.Business) string {
+	var contacts []string
+	if business.SupportEmail.Valid {
+		email := html.EscapeString(business.SupportEmail.String)
+		contacts = append(contacts, `<a href="mailto:`+email+`">`+email+`</a>`)
+	}
+	if business.SupportPhone.Valid {
+		phone := html.EscapeString(business.SupportPhone.String)
+		contacts = append(contacts, `<a href="tel:`+phone+`">`+phone+`</a>`)
+	}
+	if len(contacts) == 0 {
+		return ""
+	}
+	return `<p class="support">Need help? ` + strings.Join(contacts, " · ") + `</p>`
 }
 
 func (api *API) SucceedPayment(w http.ResponseWriter, r *http.Request) {
@@ -121,26 +162,17 @@ func (api *API) SucceedPayment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = api.db.CreatePayment(ctx, sqlc.CreatePaymentParams{
-		ID:        ksuid.New().String(),
-		SessionID: session.ID,
-		Amount:    session.Amount,
-		Currency:  session.Currency,
-		Status:    "succeeded",
-	})
-	if err != nil {
-		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to create payment"}, http.StatusInternalServerError)
+	_, updatedSession, err := api.completeCheckoutPayment(ctx, session, nil)
+	var terr *transferError
+	if errors.As(err, &terr) {
+		returnError(w, terr.err, terr.status)
 		return
 	}
-
-	updatedSession, err := api.db.SucceedCheckoutSession(ctx, sessionID)
 	if err != nil {
-		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to update session"}, http.StatusInternalServerError)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to complete payment"}, http.StatusInternalServerError)
 		return
 	}
 
-	api.webhookSender.SendWebhook(context.Background(), "checkout.session.completed", updatedSession)
-
 	http.Redirect(w, r, updatedSession.SuccessUrl, http.StatusSeeOther)
 }
 
@@ -170,36 +202,173 @@ func (api *API) FailPayment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Select a random realistic error
-	errors := []string{
+	paymentErrors := []string{
 		`{"code": "insufficient-funds", "message": "The user did not have enough account balance."}`,
 		`{"code": "blocked-account", "message": "The customer used a blocked account to try and pay for the checkout."}`,
 		`{"code": "payment-failure", "message": "A technical error has occurred in Wave's system."}`,
 	}
-	paymentError := errors[time.Now().UnixNano()%int64(len(errors))]
+	paymentError := paymentErrors[time.Now().UnixNano()%int64(len(paymentErrors))]
+
+	_, updatedSession, err := api.failCheckoutPayment(ctx, session, paymentError, nil)
+	var terr *transferError
+	if errors.As(err, &terr) {
+		returnError(w, terr.err, terr.status)
+		return
+	}
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to record payment failure"}, http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, updatedSession.ErrorUrl, http.StatusSeeOther)
+}
+
+// completeCheckoutPayment records a succeeded payment for the session, marks the
+// session complete, debits the payer's wallet, credits the pending balance of
+// the business with the payment net of fees and notifies the business. payer
+// is nil when the payment was simulated from the payment page rather than made
+// by an app user.
+func (api *API) completeCheckoutPayment(ctx context.Context, session sqlc.CheckoutSession, payer *sqlc.User) (sqlc.Payment, sqlc.CheckoutSession, error) {
+	business, err := api.db.GetBusinessByID(ctx, session.BusinessID)
+	if err != nil {
+		return sqlc.Payment{}, sqlc.CheckoutSession{}, fmt.Errorf("get business: %w", err)
+	}
+	fee := checkoutFee(business, session.Amount)
 
-	_, err = api.db.CreatePayment(ctx, sqlc.CreatePaymentParams{
+	params := sqlc.CreatePaymentParams{
+		ID:         ksuid.New().String(),
+		SessionID:  nullString(session.ID),
+		BusinessID: session.BusinessID,
+		Channel:    "checkout",
+		Amount:     session.Amount,
+		Fee:        fee,
+		NetAmount:  session.Amount - fee,
+		SettlesAt:  pgtype.Timestamptz{Time: time.Now().Add(settlementDelay), Valid: true},
+		Currency:   session.Currency,
+		Status:     "succeeded",
+		Env:        session.Env,
+	}
+	if payer != nil {
+		params.UserID = nullString(payer.ID)
+		params.PayerMobile = nullString(payer.Phone)
+		if err := api.ensureWallet(ctx, *payer); err != nil {
+			return sqlc.Payment{}, sqlc.CheckoutSession{}, fmt.Errorf("open wallet: %w", err)
+		}
+	}
+
+	var payment sqlc.Payment
+	var updatedSession sqlc.CheckoutSession
+	err = api.inTx(ctx, func(q *sqlc.Queries) error {
+		// Only one of concurrent confirmations of the session completes it.
+		var err error
+		updatedSession, err = q.SucceedCheckoutSession(ctx, session.ID)
+		if err == pgx.ErrNoRows {
+			return &transferError{http.StatusConflict, domain.LastPaymentError{
+				Code:    "checkout-session-not-open",
+				Message: "Checkout session is not open",
+			}}
+		}
+		if err != nil {
+			return fmt.Errorf("update session: %w", err)
+		}
+
+		if payer != nil {
+			wallet, err := q.GetWalletForUpdate(ctx, payer.ID)
+			if err != nil {
+				return fmt.Errorf("get wallet: %w", err)
+			}
+			if wallet.Currency != session.Currency {
+				return &transferError{http.StatusBadRequest, domain.LastPaymentError{
+					Code:    "currency-mismatch",
+					Message: "The merchant does not accept your wallet currency",
+				}}
+			}
+			if wallet.Balance < session.Amount {
+				return &transferError{http.StatusPaymentRequired, domain.LastPaymentError{
+					Code:    "insufficient-funds",
+					Message: "The user did not have enough account balance.",
+				}}
+			}
+			if err := q.UpdateWalletBalance(ctx, sqlc.UpdateWalletBalanceParams{
+				UserID:  payer.ID,
+				Balance: wallet.Balance - session.Amount,
+			}); err != nil {
+				return fmt.Errorf("debit wallet: %w", err)
+			}
+		}
+
+		payment, err = q.CreatePayment(ctx, params)
+		if err != nil {
+			return fmt.Errorf("create payment: %w", err)
+		}
+
+		if _, err := q.CreditPendingBalance(ctx, sqlc.CreditPendingBalanceParams{
+			BusinessID: session.BusinessID,
+			Pending:    payment.NetAmount,
+			Currency:   session.Currency,
+			Env:        session.Env,
+		}); err != nil {
+			return fmt.Errorf("credit balance: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return sqlc.Payment{}, sqlc.CheckoutSession{}, err
+	}
+
+	api.webhookSender.SendPaymentWebhook(context.Background(), "checkout.session.completed", updatedSession, payment)
+	return payment, updatedSession, nil
+}
+
+// failCheckoutPayment records a failed payment carrying paymentError, a JSON
+// encoded domain.LastPaymentError, and notifies the business. It fails with
+// checkout-session-not-open once the session was completed by a concurrent
+// payment.
+func (api *API) failCheckoutPayment(ctx context.Context, session sqlc.CheckoutSession, paymentError string, payer *sqlc.User) (sqlc.Payment, sqlc.CheckoutSession, error) {
+	params := sqlc.CreatePaymentParams{
 		ID:            ksuid.New().String(),
-		SessionID:     session.ID,
+		SessionID:     nullString(session.ID),
+		BusinessID:    session.BusinessID,
+		Channel:       "checkout",
 		Amount:        session.Amount,
 		Currency:      session.Currency,
 		Status:        "failed",
 		FailureReason: nullString(paymentError),
-	})
-	if err != nil {
-		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to create payment"}, http.StatusInternalServerError)
-		return
+		Env:           session.Env,
+	}
+	if payer != nil {
+		params.UserID = nullString(payer.ID)
+		params.PayerMobile = nullString(payer.Phone)
 	}
 
-	updatedSession, err := api.db.FailCheckoutSession(ctx, sqlc.FailCheckoutSessionParams{
-		ID:               sessionID,
-		LastPaymentError: []byte(paymentError),
+	var payment sqlc.Payment
+	var updatedSession sqlc.CheckoutSession
+	err := api.inTx(ctx, func(q *sqlc.Queries) error {
+		var err error
+		updatedSession, err = q.FailCheckoutSession(ctx, sqlc.FailCheckoutSessionParams{
+			ID:               session.ID,
+			LastPaymentError: []byte(paymentError),
+		})
+		if err == pgx.ErrNoRows {
+			return &transferError{http.StatusConflict, domain.LastPaymentError{
+				Code:    "checkout-session-not-open",
+				Message: "Checkout session is not open",
+			}}
+		}
+		if err != nil {
+			return fmt.Errorf("update session: %w", err)
+		}
+
+		payment, err = q.CreatePayment(ctx, params)
+		if err != nil {
+			return fmt.Errorf("create payment: %w", err)
+		}
+		return nil
 	})
 	if err != nil {
-		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to update session"}, http.StatusInternalServerError)
-		return
+		return sqlc.Payment{}, sqlc.CheckoutSession{}, err
 	}
 
 	api.webhookSender.SendWebhook(context.Background(), "checkout.session.payment_failed", updatedSession)
-
-	http.Redirect(w, r, updatedSession.ErrorUrl, http.StatusSeeOther)
+	return payment, updatedSession, nil
 }
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ChangePIN replaces the authenticated user's PIN after checking the old one,
+// then signs the user out of every device.
+// PUT /api/v1/me/pin
+func (api *API) ChangePIN(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		OldPin string `json:"old_pin"`
+		NewPin string `json:"new_pin"`
+	}
+
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "Invalid request body"}, http.StatusBadRequest)
+		return
+	}
+	if !pinPattern.MatchString(req.NewPin) {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "PIN must be 4 digits"}, http.StatusBadRequest)
+		return
+	}
+	if req.NewPin == req.OldPin {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "The new PIN must differ from the old one"}, http.StatusBadRequest)
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to load user"}, http.StatusInternalServerError)
+		return
+	}
+	if err := api.verifyPIN(r, user, req.OldPin); err != nil {
+		writePINError(w, err)
+		return
+	}
+
+	if err := api.setPIN(r.Context(), userID, req.NewPin); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to change PIN", "user_id", userID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to change PIN"}, http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, userID, "pin.changed", nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ForgotPIN sends a code for resetting the PIN to a registered phone. It
+// answers the same way for unknown phones so it cannot be used to find
+// accounts.
+// POST /api/v1/auth/pin/forgot
+func (api *API) ForgotPIN(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phone string `json:"phone"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		return
+	}
+	req.Phone = phone
+
+	if !api.allowRequest(w, r,
+		limitedKey{"otp:phone:" + req.Phone, otpRateLimit},
+		limitedKey{"auth:ip:" + clientIP(r), authIPRateLimit},
+	) {
+		return
+	}
+
+	_, err := api.findUserByMobile(r.Context(), req.Phone)
+	if err == nil {
+		err = api.sendOTP(r.Context(), "reset_pin", req.Phone)
+	} else if err == pgx.ErrNoRows {
+		err = nil
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to send PIN reset code", "phone", req.Phone, "error", err)
+		http.Error(w, "Failed to send verification code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]any{"expires_in": int64(otpTTL.Seconds())})
+}
+
+// ResetPIN sets a new PIN once the code sent by ForgotPIN is verified. The
+// account is unlocked and signed out of every device.
+// POST /api/v1/auth/pin/reset
+func (api *API) ResetPIN(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phone  string `json:"phone"`
+		Code   string `json:"code"`
+		NewPin string `json:"new_pin"`
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		http.Error(w, "Invalid phone number format", http.StatusBadRequest)
+		return
+	}
+	req.Phone = phone
+	if !otpPattern.MatchString(req.Code) {
+		http.Error(w, "Code must be 6 digits", http.StatusBadRequest)
+		return
+	}
+	if !pinPattern.MatchString(req.NewPin) {
+		http.Error(w, "PIN must be 4 digits", http.StatusBadRequest)
+		return
+	}
+
+	if !api.allowRequest(w, r,
+		limitedKey{"auth:phone:" + req.Phone, authPhoneRateLimit},
+		limitedKey{"auth:ip:" + clientIP(r), authIPRateLimit},
+	) {
+		return
+	}
+
+	if err := api.verifyOTP(r.Context(), "reset_pin", req.Phone, req.Code); err != nil {
+		if errors.Is(err, errInvalidOTP) {
+			http.Error(w, "Invalid or expired code", http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to verify code", "phone", req.Phone, "error", err)
+		http.Error(w, "Failed to verify code", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := api.findUserByMobile(r.Context(), req.Phone)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up user", "error", err)
+		http.Error(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := api.setPIN(r.Context(), user.ID, req.NewPin); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to reset PIN", "user_id", user.ID, "error", err)
+		http.Error(w, "Failed to reset PIN", http.StatusInternalServerError)
+		return
+	}
+	if err := api.unlockUser(r.Context(), user.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to unlock user", "user_id", user.ID, "error", err)
+	}
+	api.audit(r, user.ID, "pin.reset", nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setPIN stores a new PIN for the user and revokes all their sessions.
+func (api *API) setPIN(ctx context.Context, userID, pin string) error {
+	pinHash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := api.db.UpdateUserPin(ctx, sqlc.UpdateUserPinParams{ID: userID, PinHash: string(pinHash)}); err != nil {
+		return err
+	}
+	return api.revokeSessions(ctx, userID)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// pinLockThreshold is the number of wrong PINs after which each further
+	// failure locks the account for a while.
+	pinLockThreshold = 3
+	// pinMaxAttempts is the number of wrong PINs after which the account is
+	// locked until it is unlocked.
+	pinMaxAttempts = 8
+	// pinAttemptsWindow is how long failed attempts are remembered.
+	pinAttemptsWindow = 24 * time.Hour
+)
+
+// pinBackoff is how long the account is locked after the 3rd, 4th, ... wrong PIN.
+var pinBackoff = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute, time.Hour}
+
+var errInvalidPIN = errors.New("invalid pin")
+
+// pinLockedError reports a locked account. Until is zero when the account is
+// locked until it is unlocked.
+type pinLockedError struct {
+	Until time.Time
+}
+
+func (e *pinLockedError) Error() string {
+	if e.Until.IsZero() {
+		return "account locked"
+	}
+	return "account locked until " + e.Until.Format(time.RFC3339)
+}
+
+func pinAttemptsKey(userID string) string { return "pin_attempts:" + userID }
+func pinLockKey(userID string) string     { return "pin_lock:" + userID }
+
+// verifyPIN checks pin against the user's PIN hash, counting failures and
+// locking the account with escalating backoff. It returns errInvalidPIN for a
+// wrong PIN and a *pinLockedError while the account is locked.
+func (api *API) verifyPIN(r *http.Request, user sqlc.User, pin string) error {
+	ctx := r.Context()
+
+	if user.Status == "locked" {
+		return &pinLockedError{}
+	}
+	if until, err := api.redis.Get(ctx, pinLockKey(user.ID)).Int64(); err == nil && time.Now().Unix() < until {
+		return &pinLockedError{Until: time.Unix(until, 0)}
+	} else if err != nil && err != redis.Nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PinHash), []byte(pin)); err == nil {
+		api.redis.Del(ctx, pinAttemptsKey(user.ID), pinLockKey(user.ID))
+		return nil
+	}
+
+	failures, err := api.redis.Incr(ctx, pinAttemptsKey(user.ID)).Result()
+	if err != nil {
+		return err
+	}
+	if failures == 1 {
+		api.redis.Expire(ctx, pinAttemptsKey(user.ID), pinAttemptsWindow)
+	}
+
+	if failures >= pinMaxAttempts {
+		if err := api.db.UpdateUserStatus(ctx, sqlc.UpdateUserStatusParams{ID: user.ID, Status: "locked"}); err != nil {
+			return err
+		}
+		api.redis.Del(ctx, pinAttemptsKey(user.ID), pinLockKey(user.ID))
+		api.audit(r, user.ID, "account.locked", map[string]any{"failed_attempts": failures})
+		return &pinLockedError{}
+	}
+
+	if failures >= pinLockThreshold {
+		backoff := pinBackoff[min(int(failures)-pinLockThreshold, len(pinBackoff)-1)]
+		until := time.Now().Add(backoff)
+		if err := api.redis.Set(ctx, pinLockKey(user.ID), until.Unix(), backoff).Err(); err != nil {
+			return err
+		}
+		api.audit(r, user.ID, "account.temporarily_locked", map[string]any{
+			"failed_attempts": failures,
+			"locked_until":    until.UTC().Format(time.RFC3339),
+		})
+		return &pinLockedError{Until: until}
+	}
+
+	return errInvalidPIN
+}
+
+// unlockUser lifts both kinds of lock from an account and clears its failed
+// attempts.
+func (api *API) unlockUser(ctx context.Context, userID string) error {
+	if err := api.db.UpdateUserStatus(ctx, sqlc.UpdateUserStatusParams{ID: userID, Status: "active"}); err != nil {
+		return err
+	}
+	return api.redis.Del(ctx, pinAttemptsKey(userID), pinLockKey(userID)).Err()
+}
+
+// writePINError writes the response for an error returned by verifyPIN.
+func writePINError(w http.ResponseWriter, err error) {
+	var locked *pinLockedError
+	switch {
+	case errors.Is(err, errInvalidPIN):
+		returnError(w, domain.LastPaymentError{Code: "invalid-pin", Message: "The PIN is incorrect"}, http.StatusUnauthorized)
+	case errors.As(err, &locked):
+		writeAccountLocked(w, locked)
+	default:
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to verify PIN"}, http.StatusInternalServerError)
+	}
+}
+
+func writeAccountLocked(w http.ResponseWriter, locked *pinLockedError) {
+	if locked.Until.IsZero() {
+		returnError(w, domain.LastPaymentError{
+			Code:    "account-locked",
+			Message: "The account is locked after too many wrong PINs. Contact support to unlock it.",
+		}, http.StatusLocked)
+		return
+	}
+	retryAfter := int(time.Until(locked.Until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	returnError(w, domain.LastPaymentError{
+		Code:    "account-temporarily-locked",
+		Message: fmt.Sprintf("Too many wrong PINs, try again in %d seconds", retryAfter),
+		Details: locked.Until.UTC().Format(time.RFC3339),
+	}, http.StatusLocked)
+}
+
+// audit records a security event about a user.
+func (api *API) audit(r *http.Request, userID, event string, details map[string]any) {
+	raw, _ := json.Marshal(details)
+	if err := api.db.CreateAuditLog(r.Context(), sqlc.CreateAuditLogParams{
+		ID:      ksuid.New().String(),
+		UserID:  userID,
+		Event:   event,
+		Ip:      nullString(clientIP(r)),
+		Details: raw,
+	}); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write audit log", "user_id", userID, "event", event, "error", err)
+	}
+}
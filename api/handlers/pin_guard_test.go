@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newPINTest(t *testing.T) (*API, *fakeUserStore, *fakeRedis, sqlc.User) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, redis := &fakeUserStore{}, newFakeRedis()
+	user := sqlc.User{ID: "user-1", PinHash: string(hash), Status: "active"}
+	return &API{db: store, redis: redis}, store, redis, user
+}
+
+func verifyTestPIN(api *API, user sqlc.User, pin string) error {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/me/pin/verify", nil)
+	return api.verifyPIN(r, user, pin)
+}
+
+// wantLockedFor checks that err locks the account for about d.
+func wantLockedFor(t *testing.T, err error, d time.Duration) {
+	t.Helper()
+	var locked *pinLockedError
+	if !errors.As(err, &locked) || locked.Until.IsZero() {
+		t.Fatalf("err = %v, want a lock of %v", err, d)
+	}
+	if got := time.Until(locked.Until); got < d-2*time.Second || got > d {
+		t.Errorf("locked for %v, want %v", got, d)
+	}
+}
+
+func TestVerifyPINLocksAfterThreshold(t *testing.T) {
+	api, _, _, user := newPINTest(t)
+
+	for i := 1; i < pinLockThreshold; i++ {
+		if err := verifyTestPIN(api, user, "0000"); err != errInvalidPIN {
+			t.Fatalf("attempt %d: err = %v, want errInvalidPIN", i, err)
+		}
+	}
+	wantLockedFor(t, verifyTestPIN(api, user, "0000"), pinBackoff[0])
+
+	// The right PIN doesn't get through the lock.
+	wantLockedFor(t, verifyTestPIN(api, user, "1234"), pinBackoff[0])
+}
+
+func TestVerifyPINBackoffGrows(t *testing.T) {
+	api, store, redis, user := newPINTest(t)
+
+	for i := 1; i < pinLockThreshold; i++ {
+		verifyTestPIN(api, user, "0000")
+	}
+	for i := pinLockThreshold; i < pinMaxAttempts; i++ {
+		want := pinBackoff[min(i-pinLockThreshold, len(pinBackoff)-1)]
+		wantLockedFor(t, verifyTestPIN(api, user, "0000"), want)
+		// Let the lock run out.
+		redis.Del(context.Background(), pinLockKey(user.ID))
+	}
+
+	var locked *pinLockedError
+	if err := verifyTestPIN(api, user, "0000"); !errors.As(err, &locked) || !locked.Until.IsZero() {
+		t.Fatalf("err = %v, want a permanent lock", err)
+	}
+	if len(store.statuses) != 1 || store.statuses[0] != "locked" {
+		t.Errorf("statuses = %v, want [locked]", store.statuses)
+	}
+	if last := store.events[len(store.events)-1]; last != "account.locked" {
+		t.Errorf("last audit event = %q, want account.locked", last)
+	}
+
+	user.Status = "locked"
+	if err := verifyTestPIN(api, user, "1234"); !errors.As(err, &locked) || !locked.Until.IsZero() {
+		t.Errorf("err = %v, want a permanent lock for a locked account", err)
+	}
+}
+
+func TestVerifyPINSuccessResetsAttempts(t *testing.T) {
+	api, _, redis, user := newPINTest(t)
+
+	for i := 1; i < pinLockThreshold; i++ {
+		verifyTestPIN(api, user, "0000")
+	}
+	if err := verifyTestPIN(api, user, "1234"); err != nil {
+		t.Fatalf("err = %v, want nil", err)
+	}
+	if err := redis.Get(context.Background(), pinAttemptsKey(user.ID)).Err(); err == nil {
+		t.Error("failed attempts were kept after a right PIN")
+	}
+	for i := 1; i < pinLockThreshold; i++ {
+		if err := verifyTestPIN(api, user, "0000"); err != errInvalidPIN {
+			t.Fatalf("attempt %d after reset: err = %v, want errInvalidPIN", i, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/segmentio/ksuid"
+)
+
+// rateLimit allows Requests per sliding Window.
+type rateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+var (
+	// apiKeyRateLimit is the quota of each API key on the Wave API.
+	apiKeyRateLimit = rateLimit{Requests: envInt("API_KEY_RATE_LIMIT", 100), Window: time.Minute}
+	// authPhoneRateLimit and authIPRateLimit bound sign-in attempts per phone
+	// number and per client IP.
+	authPhoneRateLimit = rateLimit{Requests: 5, Window: time.Minute}
+	authIPRateLimit    = rateLimit{Requests: 20, Window: time.Minute}
+)
+
+// slidingWindowScript counts the requests made in the last window, in
+// milliseconds, in a sorted set and records the new one if it is allowed. It
+// returns whether the request is allowed, how many remain and how long until
+// the oldest request leaves the window.
+const slidingWindowScript = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	count = count + 1
+	allowed = 1
+end
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`
+
+type rateLimitResult struct {
+	Allowed   bool
+	Remaining int
+	Reset     time.Duration
+}
+
+func envInt(name string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// rateLimit records a request against key, shared by every replica through
+// Redis. Requests are let through if Redis is unavailable.
+func (api *API) rateLimit(ctx context.Context, key string, limit rateLimit) rateLimitResult {
+	res, err := api.redis.Eval(ctx, slidingWindowScript, []string{"ratelimit:" + key},
+		time.Now().UnixMilli(), limit.Window.Milliseconds(), limit.Requests, ksuid.New().String(),
+	).Int64Slice()
+	if err != nil || len(res) != 3 {
+		slog.ErrorContext(ctx, "Failed to check rate limit", "key", key, "error", err)
+		return rateLimitResult{Allowed: true, Remaining: limit.Requests, Reset: limit.Window}
+	}
+	return rateLimitResult{
+		Allowed:   res[0] == 1,
+		Remaining: int(res[1]),
+		Reset:     time.Duration(res[2]) * time.Millisecond,
+	}
+}
+
+// limitedKey is a rate limit applied to one key, such as an API key id.
+type limitedKey struct {
+	Key   string
+	Limit rateLimit
+}
+
+// allowRequest counts the request against each limit. It sets the
+// X-RateLimit-* headers of the most restrictive one, and writes a
+// rate-limit-exceeded error with Retry-After when any is exhausted.
+func (api *API) allowRequest(w http.ResponseWriter, r *http.Request, limits ...limitedKey) bool {
+	var tightest rateLimitResult
+	var tightestLimit rateLimit
+	for i, l := range limits {
+		res := api.rateLimit(r.Context(), l.Key, l.Limit)
+		if i == 0 || !res.Allowed || (tightest.Allowed && res.Remaining < tightest.Remaining) {
+			tightest, tightestLimit = res, l.Limit
+		}
+		if !res.Allowed {
+			break
+		}
+	}
+
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(tightestLimit.Requests))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(tightest.Reset).Unix(), 10))
+	if tightest.Allowed {
+		return true
+	}
+
+	retryAfter := int(math.Ceil(tightest.Reset.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	returnError(w, domain.LastPaymentError{
+		Code:    "rate-limit-exceeded",
+		Message: fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter),
+	}, http.StatusTooManyRequests)
+	return false
+}
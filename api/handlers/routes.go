@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/abdotop/wave-pool/domain"
+)
+
+// APIKeyRoute is an endpoint of the Wave API, authenticated by API key.
+type APIKeyRoute struct {
+	Pattern string
+	Scope   string
+	Handler http.HandlerFunc
+}
+
+// APIKeyRoutes lists every API key authenticated endpoint with the scope it
+// requires. main registers the router from it and ListAPIKeys uses it to show
+// which endpoints a key can call.
+func (api *API) APIKeyRoutes() []APIKeyRoute {
+	return []APIKeyRoute{
+		// Checkout
+		{"POST /v1/checkout/sessions", domain.ScopeCheckout, api.CreateCheckoutSession},
+		{"GET /v1/checkout/sessions/{session_id}", domain.ScopeCheckout, api.GetCheckoutSession},
+		{"GET /v1/checkout/sessions", domain.ScopeCheckout, api.GetCheckoutSessionByTxID},
+		{"GET /v1/checkout/sessions/search", domain.ScopeCheckout, api.SearchCheckoutSessions},
+		{"POST /v1/checkout/sessions/{session_id}/refund", domain.ScopeCheckout, api.RefundCheckoutSession},
+		{"POST /v1/checkout/sessions/{session_id}/expire", domain.ScopeCheckout, api.ExpireCheckoutSession},
+
+		// Balance & B2B payments
+		{"GET /v1/balance", domain.ScopeBalance, api.GetBalance},
+		{"GET /v1/transactions", domain.ScopeBalance, api.ListTransactions},
+		{"POST /v1/b2b/payments", domain.ScopeB2B, api.CreateB2BPayment},
+		{"GET /v1/b2b/payments/{payment_id}", domain.ScopeB2B, api.GetB2BPayment},
+		{"GET /v1/b2b/payments", domain.ScopeB2B, api.ListB2BPayments},
+	}
+}
+
+// RegisterAPIKeyRoutes adds the API key authenticated endpoints to router.
+func (api *API) RegisterAPIKeyRoutes(router *http.ServeMux) {
+	for _, route := range api.APIKeyRoutes() {
+		router.Handle(route.Pattern, api.APIKeyAuthMiddleware(route.Scope)(route.Handler))
+	}
+}
@@ -6,19 +6,33 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
 )
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey    = contextKey("user")
+	sessionContextKey = contextKey("session")
+)
+
+// refreshTokenTTL is how long a refresh token can be used.
+const refreshTokenTTL = 7 * 24 * time.Hour
 
-// Middleware to check for a valid access token and add the user to the context
+// The typ claim tells access tokens from refresh tokens, which are signed with
+// the same keys.
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+// Middleware to check for a valid access token and add the user to the context.
+// Refresh tokens are refused, and so are tokens of a session that was revoked.
 func (api *API) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -38,15 +52,7 @@ func (api *API) AuthMiddleware(next http.Handler) http.Handler {
 		tokenString := tokenParts[1]
 		claims := &jwt.MapClaims{}
 
-		jwtSecret := []byte(os.Getenv("API_SECRET"))
-		if string(jwtSecret) == "" {
-			jwtSecret = []byte("default-secret")
-		}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret, nil
-		})
-
+		token, err := api.jwtKeys.parse(tokenString, claims)
 		if err != nil {
 			if err == jwt.ErrSignatureInvalid {
 				slog.ErrorContext(r.Context(), "Invalid token signature", "error", err)
@@ -63,22 +69,66 @@ func (api *API) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if typ, _ := (*claims)["typ"].(string); typ != tokenTypeAccess {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		userID, _ := (*claims)["sub"].(string)
+		sessionID, _ := (*claims)["sid"].(string)
+		sess, err := api.loadSession(r.Context(), sessionID)
+		if err == redis.Nil || (err == nil && sess.UserID != userID) {
+			http.Error(w, "Session has been revoked", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get session from Redis", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		// Add user to context
-		ctx := context.WithValue(r.Context(), userContextKey, (*claims)["sub"])
+		ctx := context.WithValue(r.Context(), userContextKey, userID)
+		ctx = context.WithValue(ctx, sessionContextKey, sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// Refresh token endpoint
-func (api *API) Refresh(w http.ResponseWriter, r *http.Request) {
-	type request struct {
-		RefreshToken string `json:"refresh_token"`
+// refreshClaims are the claims of a refresh token: the session, which is the
+// token family, and the token's own id.
+type refreshClaims struct {
+	UserID    string
+	SessionID string
+	JTI       string
+}
+
+// parseRefreshToken verifies a refresh token and returns its claims.
+func (api *API) parseRefreshToken(tokenString string) (refreshClaims, error) {
+	claims := &jwt.MapClaims{}
+	token, err := api.jwtKeys.parse(tokenString, claims)
+	if err != nil {
+		return refreshClaims{}, err
+	}
+	if !token.Valid {
+		return refreshClaims{}, errors.New("refresh token is not valid")
 	}
 
-	type response struct {
-		AccessToken  string `json:"access_token"`
+	if typ, _ := (*claims)["typ"].(string); typ != tokenTypeRefresh {
+		return refreshClaims{}, errors.New("not a refresh token")
+	}
+	userID, _ := (*claims)["sub"].(string)
+	sessionID, _ := (*claims)["sid"].(string)
+	jti, _ := (*claims)["jti"].(string)
+	if userID == "" || sessionID == "" || jti == "" {
+		return refreshClaims{}, errors.New("refresh token is missing claims")
+	}
+	return refreshClaims{UserID: userID, SessionID: sessionID, JTI: jti}, nil
+}
+
+// Refresh token endpoint. Each refresh token can be redeemed once; presenting
+// a rotated one again means it was stolen, so its whole family is revoked.
+func (api *API) Refresh(w http.ResponseWriter, r *http.Request) {
+	type request struct {
 		RefreshToken string `json:"refresh_token"`
-		ExpiresIn    int64  `json:"expires_in"`
 	}
 
 	var req request
@@ -88,92 +138,66 @@ func (api *API) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims := &jwt.MapClaims{}
-	jwtSecret := []byte(os.Getenv("API_SECRET"))
-	if string(jwtSecret) == "" {
-		jwtSecret = []byte("default-secret")
-	}
-
-	token, err := jwt.ParseWithClaims(req.RefreshToken, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-
+	claims, err := api.parseRefreshToken(req.RefreshToken)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "Invalid refresh token", "error", err)
 		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
-	if !token.Valid {
-		http.Error(w, "Refresh token is not valid", http.StatusUnauthorized)
-		return
-	}
-
-	userID, ok := (*claims)["sub"].(string)
-	if !ok {
-		slog.ErrorContext(r.Context(), "Invalid user ID in token")
-		http.Error(w, "Invalid user ID in token", http.StatusUnauthorized)
-		return
-	}
-
-	// Check if the refresh token is in Redis
-	_, err = api.redis.Get(r.Context(), "refresh_token:"+req.RefreshToken).Result()
-	if err == redis.Nil {
+	// Check if the refresh token's session is still active
+	sess, err := api.loadSession(r.Context(), claims.SessionID)
+	if err == redis.Nil || (err == nil && sess.UserID != claims.UserID) {
 		slog.ErrorContext(r.Context(), "Refresh token not found in Redis")
 		http.Error(w, "Refresh token not found", http.StatusUnauthorized)
 		return
 	} else if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to get refresh token from Redis", "error", err)
+		slog.ErrorContext(r.Context(), "Failed to get session from Redis", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate new access and refresh tokens
-	newAccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(15 * time.Minute).Unix(),
-	})
-	newAccessTokenString, err := newAccessToken.SignedString(jwtSecret)
-	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to generate access token", "error", err)
+	// Redeem the refresh token for a new one
+	newJTI := ksuid.New().String()
+	if err := api.rotateRefreshToken(r.Context(), sess.ID, claims.JTI, newJTI); err != nil {
+		if errors.Is(err, errRefreshTokenReused) {
+			api.handleRefreshTokenReuse(r, sess)
+			http.Error(w, "Refresh token was already used", http.StatusUnauthorized)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to rotate refresh token", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	newRefreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(7 * 24 * time.Hour).Unix(),
-	})
-	newRefreshTokenString, err := newRefreshToken.SignedString(jwtSecret)
+	sess.IP = clientIP(r)
+	sess.LastUsedAt = time.Now().UTC()
+	resp, err := api.issueTokens(r.Context(), sess, newJTI)
 	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to generate refresh token", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Invalidate the old refresh token and store the new one
-	if err := api.redis.Del(r.Context(), "refresh_token:"+req.RefreshToken).Err(); err != nil {
-		slog.ErrorContext(r.Context(), "Failed to delete old refresh token from Redis", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	if err := api.redis.Set(r.Context(), "refresh_token:"+newRefreshTokenString, userID, 7*24*time.Hour).Err(); err != nil {
-		slog.ErrorContext(r.Context(), "Failed to store new refresh token in Redis", "error", err)
+		slog.ErrorContext(r.Context(), "Failed to issue tokens", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	resp := response{
-		AccessToken:  newAccessTokenString,
-		RefreshToken: newRefreshTokenString,
-		ExpiresIn:    900,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleRefreshTokenReuse revokes the token family of a replayed refresh
+// token, signing out both the thief and the owner, and records the incident.
+func (api *API) handleRefreshTokenReuse(r *http.Request, sess session) {
+	slog.WarnContext(r.Context(), "Refresh token reuse detected", "user_id", sess.UserID, "session_id", sess.ID)
+	if err := api.revokeSession(r.Context(), sess); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke session", "session_id", sess.ID, "error", err)
+	}
+	api.audit(r, sess.UserID, "session.refresh_token_reused", map[string]any{
+		"session_id": sess.ID,
+		"device":     sess.Device,
+		"session_ip": sess.IP,
+	})
+}
+
 // Logout endpoint
 func (api *API) Logout(w http.ResponseWriter, r *http.Request) {
 	type request struct {
@@ -187,12 +211,18 @@ func (api *API) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the refresh token from Redis
-	err := api.redis.Del(r.Context(), "refresh_token:"+req.RefreshToken).Err()
-	if err != nil {
-		slog.ErrorContext(r.Context(), "Failed to delete refresh token from Redis", "error", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	// End the session the refresh token belongs to. Invalid or expired tokens
+	// have nothing left to revoke.
+	if claims, err := api.parseRefreshToken(req.RefreshToken); err == nil {
+		sess, err := api.loadSession(r.Context(), claims.SessionID)
+		if err == nil && sess.UserID == claims.UserID {
+			err = api.revokeSession(r.Context(), sess)
+		}
+		if err != nil && err != redis.Nil {
+			slog.ErrorContext(r.Context(), "Failed to delete session from Redis", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	w.WriteHeader(http.StatusOK)
@@ -206,3 +236,10 @@ func GetUserFromContext(ctx context.Context) (string, error) {
 	}
 	return userID, nil
 }
+
+// getSessionFromContext returns the id of the session the access token was
+// issued for.
+func getSessionFromContext(ctx context.Context) string {
+	sessionID, _ := ctx.Value(sessionContextKey).(string)
+	return sessionID
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+)
+
+// newSessionTestAPI returns an API signing tokens with a throwaway key and
+// keeping sessions in a fake Redis.
+func newSessionTestAPI(t *testing.T) *API {
+	t.Helper()
+	keys, err := LoadJWTKeys("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &API{redis: newFakeRedis(), jwtKeys: keys}
+}
+
+// signIn opens a session for a user and returns it with its tokens.
+func signIn(t *testing.T, api *API) (session, tokenResponse) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth", nil)
+	sess := newSession(r, ksuid.New().String(), "test device")
+	tokens, err := api.issueTokens(context.Background(), sess, ksuid.New().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess, tokens
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	api := newSessionTestAPI(t)
+	handler := api.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	_, live := signIn(t, api)
+	revoked, revokedTokens := signIn(t, api)
+	if err := api.revokeSession(context.Background(), revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"access token", live.AccessToken, http.StatusNoContent},
+		{"refresh token", live.RefreshToken, http.StatusUnauthorized},
+		{"revoked session", revokedTokens.AccessToken, http.StatusUnauthorized},
+		{"garbage", "not-a-token", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func refresh(api *API, refreshToken string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", strings.NewReader(string(body)))
+	w := httptest.NewRecorder()
+	api.Refresh(w, r)
+	return w
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	api := newSessionTestAPI(t)
+	store := &fakeUserStore{}
+	api.db = store
+	sess, first := signIn(t, api)
+
+	w := refresh(api, first.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var second tokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying the rotated token signs out the whole family.
+	if w := refresh(api, first.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Fatalf("replay: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if _, err := api.loadSession(context.Background(), sess.ID); err != redis.Nil {
+		t.Errorf("loadSession after replay: err = %v, want redis.Nil", err)
+	}
+	if !slices.Contains(store.events, "session.refresh_token_reused") {
+		t.Errorf("audit events = %v, want session.refresh_token_reused", store.events)
+	}
+
+	if w := refresh(api, second.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh with the latest token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	handler := api.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/me", nil)
+	r.Header.Set("Authorization", "Bearer "+second.AccessToken)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("latest access token: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
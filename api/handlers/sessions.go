@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+)
+
+// session is a signed-in device. Its id is also the family id of the refresh
+// tokens issued to it: each refresh replaces the current token, identified by
+// its jti, and a rotated token that is presented again revokes the family.
+type session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+func sessionKey(sessionID string) string { return "session:" + sessionID }
+
+// sessionJTIKey holds the jti of the session's current refresh token.
+func sessionJTIKey(sessionID string) string { return "session_jti:" + sessionID }
+
+// userSessionsKey names the set of a user's session ids.
+func userSessionsKey(userID string) string { return "user_sessions:" + userID }
+
+// rotateJTIScript replaces the current jti of a session only if it is still
+// ARGV[1], so a refresh token can be redeemed once even by concurrent requests.
+const rotateJTIScript = `
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// newSession describes a sign-in from r. The device name falls back to the
+// User-Agent header.
+func newSession(r *http.Request, userID, deviceName string) session {
+	now := time.Now().UTC()
+	return session{
+		ID:         ksuid.New().String(),
+		UserID:     userID,
+		Device:     cmp.Or(strings.TrimSpace(deviceName), r.UserAgent(), "Unknown device"),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+}
+
+// saveSession stores the session with the jti of its current refresh token
+// and indexes it under its user. They expire with the refresh token.
+func (api *API) saveSession(ctx context.Context, sess session, jti string) error {
+	raw, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	if err := api.redis.Set(ctx, sessionKey(sess.ID), raw, refreshTokenTTL).Err(); err != nil {
+		return err
+	}
+	if err := api.redis.Set(ctx, sessionJTIKey(sess.ID), jti, refreshTokenTTL).Err(); err != nil {
+		return err
+	}
+	if err := api.redis.SAdd(ctx, userSessionsKey(sess.UserID), sess.ID).Err(); err != nil {
+		return err
+	}
+	return api.redis.Expire(ctx, userSessionsKey(sess.UserID), refreshTokenTTL).Err()
+}
+
+// rotateRefreshToken redeems the refresh token jti of a session for newJTI.
+// It returns errRefreshTokenReused if jti is no longer the current token.
+func (api *API) rotateRefreshToken(ctx context.Context, sessionID, jti, newJTI string) error {
+	rotated, err := api.redis.Eval(ctx, rotateJTIScript, []string{sessionJTIKey(sessionID)},
+		jti, newJTI, refreshTokenTTL.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if rotated == 0 {
+		return errRefreshTokenReused
+	}
+	return nil
+}
+
+// loadSession returns redis.Nil when the session has expired or was revoked.
+func (api *API) loadSession(ctx context.Context, sessionID string) (session, error) {
+	raw, err := api.redis.Get(ctx, sessionKey(sessionID)).Bytes()
+	if err != nil {
+		return session{}, err
+	}
+	var sess session
+	if err := json.Unmarshal(raw, &sess); err != nil {
+		return session{}, err
+	}
+	return sess, nil
+}
+
+// listSessions returns the user's active sessions, dropping expired ones from
+// the index.
+func (api *API) listSessions(ctx context.Context, userID string) ([]session, error) {
+	ids, err := api.redis.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]session, 0, len(ids))
+	for _, id := range ids {
+		sess, err := api.loadSession(ctx, id)
+		if err == redis.Nil {
+			api.redis.SRem(ctx, userSessionsKey(userID), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+// revokeSession ends a session so its refresh token can no longer be used.
+func (api *API) revokeSession(ctx context.Context, sess session) error {
+	if err := api.redis.Del(ctx, sessionKey(sess.ID), sessionJTIKey(sess.ID)).Err(); err != nil {
+		return err
+	}
+	return api.redis.SRem(ctx, userSessionsKey(sess.UserID), sess.ID).Err()
+}
+
+// revokeSessions ends every session of the user, signing them out of all
+// devices once their access tokens expire.
+func (api *API) revokeSessions(ctx context.Context, userID string) error {
+	sessions, err := api.listSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	keys := []string{userSessionsKey(userID)}
+	for _, sess := range sessions {
+		keys = append(keys, sessionKey(sess.ID), sessionJTIKey(sess.ID))
+	}
+	return api.redis.Del(ctx, keys...).Err()
+}
+
+// ListSessions returns the devices the authenticated user is signed in on.
+// GET /api/v1/me/sessions
+func (api *API) ListSessions(w http.ResponseWriter, r *http.Request) {
+	type sessionResponse struct {
+		ID         string    `json:"id"`
+		Device     string    `json:"device"`
+		IP         string    `json:"ip"`
+		CreatedAt  time.Time `json:"created_at"`
+		LastUsedAt time.Time `json:"last_used_at"`
+		Current    bool      `json:"current"`
+	}
+
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := api.listSessions(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to list sessions", "user_id", userID, "error", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	current := getSessionFromContext(r.Context())
+	result := make([]sessionResponse, 0, len(sessions))
+	for _, sess := range sessions {
+		result = append(result, sessionResponse{
+			ID:         sess.ID,
+			Device:     sess.Device,
+			IP:         sess.IP,
+			CreatedAt:  sess.CreatedAt,
+			LastUsedAt: sess.LastUsedAt,
+			Current:    sess.ID == current,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": result})
+}
+
+// RevokeSession signs the authenticated user out of one device.
+// DELETE /api/v1/me/sessions/{session_id}
+func (api *API) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sess, err := api.loadSession(r.Context(), r.PathValue("session_id"))
+	if err == redis.Nil || (err == nil && sess.UserID != userID) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+	if err == nil {
+		err = api.revokeSession(r.Context(), sess)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke session", "user_id", userID, "error", err)
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, userID, "session.revoked", map[string]any{"session_id": sess.ID, "device": sess.Device})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeAllSessions signs the authenticated user out of every device,
+// including the current one.
+// DELETE /api/v1/me/sessions
+func (api *API) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := api.revokeSessions(r.Context(), userID); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke sessions", "user_id", userID, "error", err)
+		http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	api.audit(r, userID, "session.revoked_all", nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/segmentio/ksuid"
+)
+
+var (
+	// settlementDelay is how long a succeeded payment stays in the pending
+	// balance of the business before it can be settled.
+	settlementDelay = envDuration("SETTLEMENT_DELAY", 24*time.Hour)
+	// settlementInterval is how often due payments are settled.
+	settlementInterval = envDuration("SETTLEMENT_INTERVAL", time.Minute)
+)
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d >= 0 {
+		return d
+	}
+	return fallback
+}
+
+// RunSettlements settles due payments every settlementInterval until ctx is
+// done.
+func (api *API) RunSettlements(ctx context.Context) {
+	ticker := time.NewTicker(settlementInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := api.settleDuePayments(ctx, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "Failed to settle payments", "error", err)
+			}
+		}
+	}
+}
+
+// settleDuePayments moves the payments due by cutoff from the pending to the
+// available balance of their business, one settlement per business and
+// environment, and notifies each business with settlement.completed.
+func (api *API) settleDuePayments(ctx context.Context, cutoff time.Time) ([]domain.Settlement, error) {
+	due, err := api.db.ListBusinessesToSettle(ctx, pgtype.Timestamptz{Time: cutoff, Valid: true})
+	if err != nil {
+		return nil, fmt.Errorf("list businesses to settle: %w", err)
+	}
+
+	settlements := make([]domain.Settlement, 0, len(due))
+	for _, d := range due {
+		settlement, paymentIDs, err := api.settleBusiness(ctx, d.BusinessID, d.Env, cutoff)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to settle business", "business_id", d.BusinessID, "env", d.Env, "error", err)
+			continue
+		}
+		if len(paymentIDs) == 0 {
+			continue
+		}
+
+		event := domain.Settlement{
+			ID:           "stl_" + settlement.ID,
+			BusinessID:   settlement.BusinessID,
+			Amount:       domain.FormatMinor(settlement.Amount, settlement.Currency),
+			Currency:     settlement.Currency,
+			PaymentCount: len(paymentIDs),
+			PaymentIDs:   paymentIDs,
+			WhenCreated:  settlement.CreatedAt.Time,
+		}
+		settlements = append(settlements, event)
+		api.webhookSender.Send(context.Background(), settlement.BusinessID, settlement.Env, "settlement.completed", settlement.ID, event)
+	}
+	return settlements, nil
+}
+
+// settleBusiness settles the payments of a business due by cutoff in one
+// transaction. Payments refunded or settled concurrently are left out; no
+// payment IDs are returned when none were left to settle.
+func (api *API) settleBusiness(ctx context.Context, businessID, env string, cutoff time.Time) (sqlc.Settlement, []string, error) {
+	var settlement sqlc.Settlement
+	var paymentIDs []string
+	err := api.inTx(ctx, func(q *sqlc.Queries) error {
+		id := ksuid.New().String()
+		payments, err := q.SettlePayments(ctx, sqlc.SettlePaymentsParams{
+			SettlementID: nullString(id),
+			BusinessID:   businessID,
+			Env:          env,
+			Cutoff:       pgtype.Timestamptz{Time: cutoff, Valid: true},
+		})
+		if err != nil {
+			return fmt.Errorf("settle payments: %w", err)
+		}
+		if len(payments) == 0 {
+			return nil
+		}
+
+		var amount int64
+		for _, p := range payments {
+			amount += p.NetAmount
+			paymentIDs = append(paymentIDs, p.ID)
+		}
+
+		if _, err := q.SettleBalance(ctx, sqlc.SettleBalanceParams{
+			Amount:     amount,
+			BusinessID: businessID,
+			Env:        env,
+		}); err != nil {
+			return fmt.Errorf("settle balance: %w", err)
+		}
+
+		settlement, err = q.CreateSettlement(ctx, sqlc.CreateSettlementParams{
+			ID:           id,
+			BusinessID:   businessID,
+			Env:          env,
+			Amount:       amount,
+			Currency:     payments[0].Currency,
+			PaymentCount: int32(len(payments)),
+		})
+		if err != nil {
+			return fmt.Errorf("create settlement: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return sqlc.Settlement{}, nil, err
+	}
+	return settlement, paymentIDs, nil
+}
+
+// RunSettlementsNow settles every payment already due instead of waiting for
+// the next scheduled run, to test cash flows without the delay.
+// POST /api/v1/admin/settlements/run
+func (api *API) RunSettlementsNow(w http.ResponseWriter, r *http.Request) {
+	settlements, err := api.settleDuePayments(r.Context(), time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to settle payments", "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to settle payments"}, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"result": settlements})
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// transactionResponse is one entry of the authenticated user's history.
+type transactionResponse struct {
+	ID                 string     `json:"id"`
+	Type               string     `json:"type"`
+	MerchantName       string     `json:"merchant_name,omitempty"`
+	CounterpartyMobile string     `json:"counterparty_mobile,omitempty"`
+	Amount             string     `json:"amount"`
+	Fee                string     `json:"fee"`
+	Currency           string     `json:"currency"`
+	Status             string     `json:"status"`
+	WhenCreated        time.Time  `json:"when_created"`
+	WhenCompleted      *time.Time `json:"when_completed,omitempty"`
+}
+
+// parsePage reads the limit and offset query parameters of a list endpoint.
+func parsePage(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a positive integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+type transactionsPage struct {
+	Result     []transactionResponse `json:"result"`
+	HasMore    bool                  `json:"has_more"`
+	NextOffset *int                  `json:"next_offset,omitempty"`
+}
+
+// ListMyTransactions returns the payments made, refunds received and P2P
+// transfers of the authenticated user, most recent first. Refunds have the id
+// of their payment prefixed with refund_, so every entry has its own id.
+// GET /api/v1/me/transactions?limit=20&offset=0
+func (api *API) ListMyTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Fetch one extra row to know whether another page follows.
+	rows, err := api.db.ListUserTransactions(r.Context(), sqlc.ListUserTransactionsParams{
+		UserID: nullString(userID),
+		Limit:  int32(limit + 1),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		http.Error(w, "Failed to list transactions", http.StatusInternalServerError)
+		return
+	}
+
+	page := transactionsPage{Result: make([]transactionResponse, 0, limit)}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		next := offset + limit
+		page.HasMore = true
+		page.NextOffset = &next
+	}
+
+	for _, row := range rows {
+		tx := transactionResponse{
+			ID:                 row.ID,
+			Type:               row.Kind,
+			MerchantName:       row.BusinessName,
+			CounterpartyMobile: row.CounterpartyMobile,
+			Amount:             domain.FormatMinor(row.Amount, row.Currency),
+			Fee:                domain.FormatMinor(row.Fee, row.Currency),
+			Currency:           row.Currency,
+			Status:             row.Status,
+			WhenCreated:        row.WhenCreated.Time,
+		}
+		if row.WhenCompleted.Valid {
+			tx.WhenCompleted = &row.WhenCompleted.Time
+		}
+		page.Result = append(page.Result, tx)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abdotop/wave-pool/db/sqlc"
+	"github.com/abdotop/wave-pool/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/segmentio/ksuid"
+)
+
+type createTransferRequest struct {
+	RecipientMobile string `json:"recipient_mobile"`
+	Amount          string `json:"amount"`
+	Pin             string `json:"pin"`
+	Note            string `json:"note,omitempty"`
+}
+
+type transferResponse struct {
+	ID              string    `json:"id"`
+	SenderMobile    string    `json:"sender_mobile"`
+	RecipientMobile string    `json:"recipient_mobile"`
+	Amount          string    `json:"amount"`
+	Fee             string    `json:"fee"`
+	Currency        string    `json:"currency"`
+	Status          string    `json:"status"`
+	Note            *string   `json:"note,omitempty"`
+	WhenCreated     time.Time `json:"when_created"`
+}
+
+type walletTierResponse struct {
+	Name               string `json:"name"`
+	MaxBalance         string `json:"max_balance"`
+	MaxTransfer        string `json:"max_transfer"`
+	DailyTransferLimit string `json:"daily_transfer_limit"`
+}
+
+type walletResponse struct {
+	Balance  string             `json:"balance"`
+	Currency string             `json:"currency"`
+	Tier     walletTierResponse `json:"tier"`
+}
+
+type transferQuoteResponse struct {
+	Amount   string `json:"amount"`
+	Fee      string `json:"fee"`
+	Total    string `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// transferError aborts a transfer transaction with a client facing error.
+type transferError struct {
+	status int
+	err    domain.LastPaymentError
+}
+
+func (e *transferError) Error() string {
+	return e.err.Message
+}
+
+// userCountry returns the market of the user's phone number.
+func userCountry(user sqlc.User) domain.Country {
+	_, country, ok := domain.ParsePhone(user.Phone, domain.DefaultCountry)
+	if !ok {
+		country, _ = domain.LookupCountry(domain.DefaultCountry)
+	}
+	return country
+}
+
+// ensureWallet creates the user's simulated wallet, in the currency of their
+// country, with its opening balance if it doesn't exist yet.
+func (api *API) ensureWallet(ctx context.Context, user sqlc.User) error {
+	currency := userCountry(user).Currency
+	opening, err := domain.ParseMoney(cmp.Or(os.Getenv("WALLET_INITIAL_BALANCE"), "100000"), currency)
+	if err != nil {
+		return fmt.Errorf("WALLET_INITIAL_BALANCE: %w", err)
+	}
+	return api.db.EnsureWallet(ctx, sqlc.EnsureWalletParams{
+		UserID:   user.ID,
+		Balance:  opening.Minor,
+		Currency: currency,
+	})
+}
+
+// findUserByMobile looks a user up by phone, with or without its dial code.
+// It returns pgx.ErrNoRows for numbers outside the supported markets.
+func (api *API) findUserByMobile(ctx context.Context, mobile string) (sqlc.User, error) {
+	return findUserByMobile(ctx, api.db, mobile)
+}
+
+// findUserByMobile is the lookup behind API.findUserByMobile, run against q so
+// that it can take part in a transaction.
+func findUserByMobile(ctx context.Context, q sqlc.Querier, mobile string) (sqlc.User, error) {
+	phone, country, ok := domain.ParsePhone(mobile, domain.DefaultCountry)
+	if !ok {
+		return sqlc.User{}, pgx.ErrNoRows
+	}
+	user, err := q.GetUserByPhone(ctx, phone)
+	if err == pgx.ErrNoRows && country.Code == domain.DefaultCountry {
+		// The first accounts were stored without the Senegalese dial code.
+		return q.GetUserByPhone(ctx, strings.TrimPrefix(phone, country.DialCode))
+	}
+	return user, err
+}
+
+// GetMyWallet returns the authenticated user's wallet balance and limits.
+// GET /api/v1/me/wallet
+func (api *API) GetMyWallet(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := api.ensureWallet(r.Context(), user); err != nil {
+		http.Error(w, "Failed to open wallet", http.StatusInternalServerError)
+		return
+	}
+
+	wallet, err := api.db.GetWallet(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to get wallet", http.StatusInternalServerError)
+		return
+	}
+
+	tier := domain.TierFor(user.Tier, wallet.Currency)
+	resp := walletResponse{
+		Balance:  domain.FormatMinor(wallet.Balance, wallet.Currency),
+		Currency: wallet.Currency,
+		Tier: walletTierResponse{
+			Name:               tier.Name,
+			MaxBalance:         domain.FormatMinor(tier.MaxBalance, wallet.Currency),
+			MaxTransfer:        domain.FormatMinor(tier.MaxTransfer, wallet.Currency),
+			DailyTransferLimit: domain.FormatMinor(tier.DailyTransferLimit, wallet.Currency),
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// QuoteTransfer returns the fee the sender would pay for a transfer.
+// GET /api/v1/transfers/quote?amount=1000
+func (api *API) QuoteTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "unauthorized", Message: "Unauthorized"}, http.StatusUnauthorized)
+		return
+	}
+	user, err := api.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "user-not-found", Message: "User not found"}, http.StatusNotFound)
+		return
+	}
+
+	country := userCountry(user)
+	amount, err := parseAmount(r.URL.Query().Get("amount"), country)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+
+	fee := country.TransferFee.Of(amount.Minor)
+	resp := transferQuoteResponse{
+		Amount:   amount.String(),
+		Fee:      domain.FormatMinor(fee, country.Currency),
+		Total:    domain.FormatMinor(amount.Minor+fee, country.Currency),
+		Currency: country.Currency,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateTransfer sends money from the authenticated user to another user's
+// wallet in the same currency. The sender pays the transfer fee of their
+// country and must re-confirm their PIN.
+// POST /api/v1/transfers
+func (api *API) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req createTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "Invalid JSON body"}, http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Note) > 255 {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "note is too long"}, http.StatusBadRequest)
+		return
+	}
+
+	sender, ok := api.payingUser(w, r)
+	if !ok {
+		return
+	}
+
+	country := userCountry(sender)
+	money, err := parseAmount(req.Amount, country)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{
+			Code:    "request-validation-error",
+			Message: err.Error(),
+		}, http.StatusBadRequest)
+		return
+	}
+	amount := money.Minor
+
+	if err := api.verifyPIN(r, sender, req.Pin); err != nil {
+		writePINError(w, err)
+		return
+	}
+
+	recipient, err := api.findUserByMobile(ctx, req.RecipientMobile)
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "recipient-not-found", Message: "No Wave account for this mobile number"}, http.StatusNotFound)
+		return
+	}
+	if recipient.ID == sender.ID {
+		returnError(w, domain.LastPaymentError{Code: "request-validation-error", Message: "You cannot send money to yourself"}, http.StatusBadRequest)
+		return
+	}
+	if recipient.Status != "active" {
+		returnError(w, domain.LastPaymentError{Code: "blocked-account", Message: "The recipient's account is blocked"}, http.StatusConflict)
+		return
+	}
+
+	if country.Currency != userCountry(recipient).Currency {
+		returnError(w, domain.LastPaymentError{
+			Code:    "currency-mismatch",
+			Message: "The recipient's wallet holds another currency",
+		}, http.StatusBadRequest)
+		return
+	}
+	senderTier := domain.TierFor(sender.Tier, country.Currency)
+	if amount > senderTier.MaxTransfer {
+		returnError(w, domain.LastPaymentError{
+			Code:    "transfer-limit-exceeded",
+			Message: "The amount exceeds the maximum transfer of your account tier",
+		}, http.StatusBadRequest)
+		return
+	}
+
+	for _, user := range []sqlc.User{sender, recipient} {
+		if err := api.ensureWallet(ctx, user); err != nil {
+			slog.ErrorContext(ctx, "Failed to open wallet", "user_id", user.ID, "error", err)
+			returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to open wallet"}, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	fee := country.TransferFee.Of(amount)
+	var transfer sqlc.Transfer
+	err = api.inTx(ctx, func(q *sqlc.Queries) error {
+		// Lock both wallets in a stable order so crossing transfers can't deadlock.
+		ids := []string{sender.ID, recipient.ID}
+		if ids[1] < ids[0] {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+		balances := make(map[string]int64, 2)
+		for _, id := range ids {
+			wallet, err := q.GetWalletForUpdate(ctx, id)
+			if err != nil {
+				return err
+			}
+			balances[id] = wallet.Balance
+		}
+
+		if balances[sender.ID] < amount+fee {
+			return &transferError{http.StatusPaymentRequired, domain.LastPaymentError{
+				Code:    "insufficient-funds",
+				Message: "The user did not have enough account balance.",
+			}}
+		}
+
+		sentToday, err := q.SumTransfersSentSince(ctx, sqlc.SumTransfersSentSinceParams{
+			SenderID:  sender.ID,
+			CreatedAt: pgtype.Timestamptz{Time: time.Now().UTC().Truncate(24 * time.Hour), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		if sentToday+amount > senderTier.DailyTransferLimit {
+			return &transferError{http.StatusBadRequest, domain.LastPaymentError{
+				Code:    "daily-limit-exceeded",
+				Message: "The transfer exceeds the daily limit of your account tier",
+			}}
+		}
+
+		if balances[recipient.ID]+amount > domain.TierFor(recipient.Tier, country.Currency).MaxBalance {
+			return &transferError{http.StatusConflict, domain.LastPaymentError{
+				Code:    "recipient-limit-exceeded",
+				Message: "The recipient's wallet cannot hold this amount",
+			}}
+		}
+
+		if err := q.UpdateWalletBalance(ctx, sqlc.UpdateWalletBalanceParams{
+			UserID:  sender.ID,
+			Balance: balances[sender.ID] - amount - fee,
+		}); err != nil {
+			return err
+		}
+		if err := q.UpdateWalletBalance(ctx, sqlc.UpdateWalletBalanceParams{
+			UserID:  recipient.ID,
+			Balance: balances[recipient.ID] + amount,
+		}); err != nil {
+			return err
+		}
+
+		transfer, err = q.CreateTransfer(ctx, sqlc.CreateTransferParams{
+			ID:          ksuid.New().String(),
+			SenderID:    sender.ID,
+			RecipientID: recipient.ID,
+			Amount:      amount,
+			Fee:         fee,
+			Currency:    country.Currency,
+			Status:      "succeeded",
+			Note:        nullString(req.Note),
+		})
+		return err
+	})
+	if err != nil {
+		var terr *transferError
+		if errors.As(err, &terr) {
+			returnError(w, terr.err, terr.status)
+			return
+		}
+		slog.ErrorContext(ctx, "Failed to transfer", "sender_id", sender.ID, "error", err)
+		returnError(w, domain.LastPaymentError{Code: "internal-server-error", Message: "Failed to transfer"}, http.StatusInternalServerError)
+		return
+	}
+
+	resp := transferResponse{
+		ID:              transfer.ID,
+		SenderMobile:    sender.Phone,
+		RecipientMobile: recipient.Phone,
+		Amount:          domain.FormatMinor(transfer.Amount, transfer.Currency),
+		Fee:             domain.FormatMinor(transfer.Fee, transfer.Currency),
+		Currency:        transfer.Currency,
+		Status:          transfer.Status,
+		Note:            nullableToPtr(transfer.Note),
+		WhenCreated:     transfer.CreatedAt.Time,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetTransfer returns a transfer the authenticated user sent or received.
+// GET /api/v1/transfers/{transfer_id}
+func (api *API) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	userID, err := GetUserFromContext(r.Context())
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := api.db.GetTransferForUser(r.Context(), sqlc.GetTransferForUserParams{
+		ID:     r.PathValue("transfer_id"),
+		UserID: userID,
+	})
+	if err != nil {
+		returnError(w, domain.LastPaymentError{Code: "transfer-not-found", Message: "Transfer not found"}, http.StatusNotFound)
+		return
+	}
+
+	resp := transferResponse{
+		ID:              transfer.ID,
+		SenderMobile:    transfer.SenderMobile,
+		RecipientMobile: transfer.RecipientMobile,
+		Amount:          domain.FormatMinor(transfer.Amount, transfer.Currency),
+		Fee:             domain.FormatMinor(transfer.Fee, transfer.Currency),
+		Currency:        transfer.Currency,
+		Status:          transfer.Status,
+		Note:            nullableToPtr(transfer.Note),
+		WhenCreated:     transfer.CreatedAt.Time,
+	}
+	// The fee is only charged to, and shown to, the sender.
+	if transfer.SenderID != userID {
+		resp.Fee = domain.FormatMinor(0, transfer.Currency)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -15,10 +15,11 @@ type businessResponse struct {
 
 // userResponse represents the response for user information
 type userResponse struct {
-	ID        string           `json:"id"`
-	Phone     string           `json:"phone"`
-	CreatedAt string           `json:"created_at"`
-	Business  businessResponse `json:"business"`
+	ID         string                   `json:"id"`
+	Phone      string                   `json:"phone"`
+	CreatedAt  string                   `json:"created_at"`
+	Business   businessResponse         `json:"business"`
+	Businesses []memberBusinessResponse `json:"businesses"`
 }
 
 func (api *API) GetMe(w http.ResponseWriter, r *http.Request) {
@@ -34,12 +35,25 @@ func (api *API) GetMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
+	businesses, err := api.db.ListBusinessesForUser(r.Context(), user.ID)
 	if err != nil {
+		http.Error(w, "Failed to list businesses", http.StatusInternalServerError)
+		return
+	}
+	if len(businesses) == 0 {
 		http.Error(w, "Business not found", http.StatusNotFound)
 		return
 	}
 
+	// The active business is the one selected by X-Business-ID, else the
+	// first the user joined.
+	business := businesses[0]
+	for _, b := range businesses {
+		if b.ID == r.Header.Get("X-Business-ID") {
+			business = b
+		}
+	}
+
 	resp := userResponse{
 		ID:        user.ID,
 		Phone:     user.Phone,
@@ -50,6 +64,7 @@ func (api *API) GetMe(w http.ResponseWriter, r *http.Request) {
 			Country:  business.Country,
 			Currency: business.Currency,
 		},
+		Businesses: newMemberBusinessResponses(businesses),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -26,27 +26,62 @@ func NewWebhookSender(db *sqlc.Queries) *WebhookSender {
 	return &WebhookSender{db: db}
 }
 
+// checkoutSessionEvent is the data of checkout.session.* events: the session
+// with its amount formatted as a decimal, like everywhere in the API, and the
+// fee breakdown once it is paid.
+type checkoutSessionEvent struct {
+	sqlc.CheckoutSession
+	Amount    string  `json:"amount"`
+	Fee       *string `json:"fee,omitempty"`
+	NetAmount *string `json:"net_amount,omitempty"`
+}
+
+// SendWebhook notifies the session's business of a checkout event.
 func (s *WebhookSender) SendWebhook(ctx context.Context, eventType string, session sqlc.CheckoutSession) {
-	webhooks, err := s.db.ListWebhooksByBusinessID(ctx, session.BusinessID)
+	s.Send(ctx, session.BusinessID, session.Env, eventType, session.ID, checkoutSessionEvent{
+		CheckoutSession: session,
+		Amount:          domain.FormatMinor(session.Amount, session.Currency),
+	})
+}
+
+// SendPaymentWebhook notifies the session's business of a checkout event with
+// the fee withheld from the session's succeeded payment.
+func (s *WebhookSender) SendPaymentWebhook(ctx context.Context, eventType string, session sqlc.CheckoutSession, payment sqlc.Payment) {
+	fee := domain.FormatMinor(payment.Fee, payment.Currency)
+	net := domain.FormatMinor(payment.NetAmount, payment.Currency)
+	s.Send(ctx, session.BusinessID, session.Env, eventType, session.ID, checkoutSessionEvent{
+		CheckoutSession: session,
+		Amount:          domain.FormatMinor(session.Amount, session.Currency),
+		Fee:             &fee,
+		NetAmount:       &net,
+	})
+}
+
+// Send delivers an event about the object identified by objectID to every
+// webhook the business registered for env.
+func (s *WebhookSender) Send(ctx context.Context, businessID, env, eventType, objectID string, data interface{}) {
+	webhooks, err := s.db.ListWebhooksForEnv(ctx, sqlc.ListWebhooksForEnvParams{
+		BusinessID: businessID,
+		Env:        env,
+	})
 	if err != nil {
-		log.Printf("Failed to list webhooks for business %s: %v", session.BusinessID, err)
+		log.Printf("Failed to list webhooks for business %s: %v", businessID, err)
 		return
 	}
 
-	slog.Info("Sending webhooks", "hooks", webhooks, "business_id", session.BusinessID, "event_type", eventType)
+	slog.Info("Sending webhooks", "hooks", webhooks, "business_id", businessID, "env", env, "event_type", eventType)
 
-	for _, webhook := range webhooks {
-		go s.send(ctx, webhook, eventType, session)
-	}
-}
-
-func (s *WebhookSender) send(ctx context.Context, webhook sqlc.Webhook, eventType string, session sqlc.CheckoutSession) {
 	event := domain.Event{
-		ID:   "EV_" + session.ID,
+		ID:   "EV_" + objectID,
 		Type: eventType,
-		Data: session,
+		Data: data,
 	}
+	for _, webhook := range webhooks {
+		go s.send(ctx, webhook, event)
+	}
+}
 
+func (s *WebhookSender) send(ctx context.Context, webhook sqlc.Webhook, event domain.Event) {
 	payload, err := json.Marshal(event)
 	if err != nil {
 		log.Printf("Failed to marshal webhook payload: %v", err)
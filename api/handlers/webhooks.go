@@ -18,6 +18,7 @@ type webhookPayload struct {
 	URL             string                 `json:"url"`
 	SigningStrategy domain.SigningStrategy `json:"signing_strategy"`
 	Events          []string               `json:"events"`
+	Env             string                 `json:"env"`
 }
 
 type webhookUpdatePayload struct {
@@ -35,6 +36,7 @@ type webhookResponse struct {
 	Secret          string    `json:"secret,omitempty"`
 	Events          []string  `json:"events"`
 	Status          string    `json:"status"`
+	Env             string    `json:"env"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -75,26 +77,21 @@ func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageWebhooks)
+	if !ok {
 		return
 	}
 
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	if !IsURL(payload.URL) {
+		http.Error(w, "invalid url", http.StatusBadRequest)
 		return
 	}
 
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
-		return
+	if payload.Env == "" {
+		payload.Env = domain.EnvProd
 	}
-
-	if !IsURL(payload.URL) {
-		http.Error(w, "invalid url", http.StatusBadRequest)
+	if !domain.ValidEnv(payload.Env) {
+		http.Error(w, "env must be sandbox or prod", http.StatusBadRequest)
 		return
 	}
 
@@ -115,6 +112,7 @@ func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		Secret:          secretKey,
 		Events:          payload.Events,
 		Status:          domain.WebhookStatusActive.String(),
+		Env:             payload.Env,
 	})
 	if err != nil {
 		http.Error(w, "failed to create webhook", http.StatusInternalServerError)
@@ -129,6 +127,7 @@ func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		Secret:          secretKey,
 		Events:          webhook.Events,
 		Status:          webhook.Status,
+		Env:             webhook.Env,
 		CreatedAt:       webhook.CreatedAt.Time,
 		UpdatedAt:       webhook.UpdatedAt.Time,
 	}
@@ -138,21 +137,8 @@ func (api *API) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) ListWebhooks(w http.ResponseWriter, r *http.Request) {
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermViewWebhooks)
+	if !ok {
 		return
 	}
 	webhooks, err := api.db.ListWebhooksByBusinessID(r.Context(), business.ID)
@@ -170,6 +156,7 @@ func (api *API) ListWebhooks(w http.ResponseWriter, r *http.Request) {
 			SigningStrategy: webhook.SigningStrategy,
 			Events:          webhook.Events,
 			Status:          webhook.Status,
+			Env:             webhook.Env,
 			CreatedAt:       webhook.CreatedAt.Time,
 			UpdatedAt:       webhook.UpdatedAt.Time,
 		})
@@ -188,21 +175,13 @@ func (api *API) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageWebhooks)
+	if !ok {
 		return
 	}
 
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
-		return
-	}
-
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	if _, err := api.db.GetWebhookByID(r.Context(), sqlc.GetWebhookByIDParams{ID: webhookID.String(), BusinessID: business.ID}); err != nil {
+		http.Error(w, "webhook not found", http.StatusNotFound)
 		return
 	}
 
@@ -236,6 +215,7 @@ func (api *API) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
 		SigningStrategy: webhook.SigningStrategy,
 		Events:          webhook.Events,
 		Status:          webhook.Status,
+		Env:             webhook.Env,
 		CreatedAt:       webhook.CreatedAt.Time,
 		UpdatedAt:       webhook.UpdatedAt.Time,
 	}
@@ -252,21 +232,13 @@ func (api *API) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := GetUserFromContext(r.Context())
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	user, err := api.db.GetUserByID(r.Context(), userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+	business, _, ok := api.authorizeBusiness(w, r, domain.PermManageWebhooks)
+	if !ok {
 		return
 	}
 
-	business, err := api.db.GetBusinessByOwnerID(r.Context(), user.ID)
-	if err != nil || business.OwnerID != user.ID {
-		http.Error(w, "Business not found", http.StatusNotFound)
+	if _, err := api.db.GetWebhookByID(r.Context(), sqlc.GetWebhookByIDParams{ID: webhookID.String(), BusinessID: business.ID}); err != nil {
+		http.Error(w, "webhook not found", http.StatusNotFound)
 		return
 	}
 
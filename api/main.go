@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"time"
 
 	"github.com/abdotop/wave-pool/db/sqlc"
@@ -17,41 +16,6 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// Rate Limiter
-var (
-	clients = make(map[string]*client)
-	mu      sync.Mutex
-)
-
-type client struct {
-	lastSeen time.Time
-	requests int
-}
-
-func rateLimiter(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		mu.Lock()
-		ip := r.RemoteAddr
-		if c, found := clients[ip]; found {
-			if time.Since(c.lastSeen) > 1*time.Minute {
-				c.requests = 1
-				c.lastSeen = time.Now()
-			} else {
-				c.requests++
-			}
-			if c.requests > 10 {
-				mu.Unlock()
-				http.Error(w, "Too many requests", http.StatusTooManyRequests)
-				return
-			}
-		} else {
-			clients[ip] = &client{lastSeen: time.Now(), requests: 1}
-		}
-		mu.Unlock()
-		next.ServeHTTP(w, r)
-	})
-}
-
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
 	defer cancel()
@@ -77,7 +41,21 @@ func main() {
 		log.Fatal("Unable to connect to Redis:", err)
 	}
 
-	api := handlers.NewAPI(db, rdb)
+	// API_SECRET salts legacy API keys and signs QR codes
+	if os.Getenv("ENV") == "production" && os.Getenv("API_SECRET") == "" {
+		log.Fatal("API_SECRET is required in production")
+	}
+
+	// Keys signing user tokens
+	jwtKeys, err := handlers.LoadJWTKeys(os.Getenv("JWT_KEYS_DIR"), os.Getenv("JWT_SIGNING_KID"))
+	if err != nil {
+		log.Fatal("Unable to load JWT keys:", err)
+	}
+
+	api := handlers.NewAPI(db, dbpool, rdb, jwtKeys)
+
+	// Move settled payments from pending to available balances
+	go api.RunSettlements(ctx)
 
 	// Simple HTTP server with a health check endpoint
 	router := http.NewServeMux()
@@ -85,35 +63,81 @@ func main() {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
-
-	router.Handle("POST /api/v1/auth", rateLimiter(http.HandlerFunc(api.Auth)))
+	router.Handle("GET /.well-known/jwks.json", http.HandlerFunc(api.JWKS))
+	router.Handle("GET /api/v1/countries", http.HandlerFunc(api.ListCountries))
+
+	router.Handle("POST /api/v1/auth", http.HandlerFunc(api.Auth))
+	router.Handle("POST /api/v1/auth/register", http.HandlerFunc(api.Register))
+	router.Handle("POST /api/v1/auth/register/verify", http.HandlerFunc(api.VerifyRegistration))
+	router.Handle("POST /api/v1/auth/pin/forgot", http.HandlerFunc(api.ForgotPIN))
+	router.Handle("POST /api/v1/auth/pin/reset", http.HandlerFunc(api.ResetPIN))
+	router.Handle("GET /api/v1/dev/sms/{phone}", http.HandlerFunc(api.ListSMSOutbox))
 	router.Handle("POST /api/v1/auth/refresh", http.HandlerFunc(api.Refresh))
 	router.Handle("DELETE /api/v1/auth/logout", http.HandlerFunc(api.Logout))
+	router.Handle("POST /api/v1/admin/users/{user_id}/unlock", api.AdminMiddleware(http.HandlerFunc(api.UnlockUser)))
+	router.Handle("PUT /api/v1/admin/businesses/{business_id}/fees", api.AdminMiddleware(http.HandlerFunc(api.SetBusinessFees)))
+	router.Handle("POST /api/v1/admin/settlements/run", api.AdminMiddleware(http.HandlerFunc(api.RunSettlementsNow)))
 
 	// Protected endpoints
 	router.Handle("GET /api/v1/me", api.AuthMiddleware(http.HandlerFunc(api.GetMe)))
-	// API Keys
-	router.Handle("POST /api/v1/api-keys", api.AuthMiddleware(http.HandlerFunc(api.CreateAPIKey)))
-	router.Handle("GET /api/v1/api-keys", api.AuthMiddleware(http.HandlerFunc(api.ListAPIKeys)))
-	router.Handle("DELETE /api/v1/api-keys/{key_id}", api.AuthMiddleware(http.HandlerFunc(api.DeleteAPIKey)))
-	// Webhooks
-	router.Handle("POST /api/v1/webhooks", api.AuthMiddleware(http.HandlerFunc(api.CreateWebhook)))
-	router.Handle("GET /api/v1/webhooks", api.AuthMiddleware(http.HandlerFunc(api.ListWebhooks)))
-	router.Handle("PUT /api/v1/webhooks/{webhook_id}", api.AuthMiddleware(http.HandlerFunc(api.UpdateWebhook)))
-	router.Handle("DELETE /api/v1/webhooks/{webhook_id}", api.AuthMiddleware(http.HandlerFunc(api.DeleteWebhook)))
-
-	// Checkout
-	router.Handle("POST /v1/checkout/sessions", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.CreateCheckoutSession)))
-	router.Handle("GET /v1/checkout/sessions/{session_id}", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.GetCheckoutSession)))
-	router.Handle("GET /v1/checkout/sessions", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.GetCheckoutSessionByTxID)))
-	router.Handle("GET /v1/checkout/sessions/search", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.SearchCheckoutSessions)))
-	router.Handle("POST /v1/checkout/sessions/{session_id}/refund", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.RefundCheckoutSession)))
-	router.Handle("POST /v1/checkout/sessions/{session_id}/expire", api.APIKeyAuthMiddleware("checkout")(http.HandlerFunc(api.ExpireCheckoutSession)))
+	router.Handle("GET /api/v1/me/transactions", api.AuthMiddleware(http.HandlerFunc(api.ListMyTransactions)))
+	router.Handle("GET /api/v1/me/sessions", api.AuthMiddleware(http.HandlerFunc(api.ListSessions)))
+	router.Handle("DELETE /api/v1/me/sessions", api.AuthMiddleware(http.HandlerFunc(api.RevokeAllSessions)))
+	router.Handle("DELETE /api/v1/me/sessions/{session_id}", api.AuthMiddleware(http.HandlerFunc(api.RevokeSession)))
+	router.Handle("PUT /api/v1/me/pin", api.AuthMiddleware(http.HandlerFunc(api.ChangePIN)))
+	router.Handle("GET /api/v1/me/wallet", api.AuthMiddleware(http.HandlerFunc(api.GetMyWallet)))
+	// P2P transfers
+	router.Handle("GET /api/v1/transfers/quote", api.AuthMiddleware(http.HandlerFunc(api.QuoteTransfer)))
+	router.Handle("POST /api/v1/transfers", api.AuthMiddleware(http.HandlerFunc(api.CreateTransfer)))
+	router.Handle("GET /api/v1/transfers/{transfer_id}", api.AuthMiddleware(http.HandlerFunc(api.GetTransfer)))
+	// Businesses
+	router.Handle("POST /api/v1/businesses", api.AuthMiddleware(http.HandlerFunc(api.CreateBusiness)))
+	router.Handle("GET /api/v1/businesses", api.AuthMiddleware(http.HandlerFunc(api.ListBusinesses)))
+	router.Handle("GET /api/v1/me/invitations", api.AuthMiddleware(http.HandlerFunc(api.ListMyInvitations)))
+	router.Handle("POST /api/v1/me/invitations/{invitation_id}/accept", api.AuthMiddleware(http.HandlerFunc(api.AcceptInvitation)))
+
+	// Dashboard endpoints acting on a business, selected by the path segment
+	// or the X-Business-ID header
+	for _, prefix := range []string{"/api/v1/business", "/api/v1/businesses/{business_id}"} {
+		// Business profile
+		router.Handle("GET "+prefix+"/profile", api.AuthMiddleware(http.HandlerFunc(api.GetBusinessProfile)))
+		router.Handle("PUT "+prefix+"/profile", api.AuthMiddleware(http.HandlerFunc(api.UpdateBusinessProfile)))
+		// Business members
+		router.Handle("GET "+prefix+"/members", api.AuthMiddleware(http.HandlerFunc(api.ListBusinessMembers)))
+		router.Handle("PUT "+prefix+"/members/{user_id}", api.AuthMiddleware(http.HandlerFunc(api.UpdateBusinessMember)))
+		router.Handle("DELETE "+prefix+"/members/{user_id}", api.AuthMiddleware(http.HandlerFunc(api.RemoveBusinessMember)))
+		router.Handle("POST "+prefix+"/invitations", api.AuthMiddleware(http.HandlerFunc(api.InviteBusinessMember)))
+		router.Handle("GET "+prefix+"/invitations", api.AuthMiddleware(http.HandlerFunc(api.ListBusinessInvitations)))
+		router.Handle("DELETE "+prefix+"/invitations/{invitation_id}", api.AuthMiddleware(http.HandlerFunc(api.RevokeBusinessInvitation)))
+	}
+	for _, prefix := range []string{"/api/v1", "/api/v1/businesses/{business_id}"} {
+		// API Keys
+		router.Handle("POST "+prefix+"/api-keys", api.AuthMiddleware(http.HandlerFunc(api.CreateAPIKey)))
+		router.Handle("GET "+prefix+"/api-keys", api.AuthMiddleware(http.HandlerFunc(api.ListAPIKeys)))
+		router.Handle("DELETE "+prefix+"/api-keys/{key_id}", api.AuthMiddleware(http.HandlerFunc(api.DeleteAPIKey)))
+		router.Handle("POST "+prefix+"/api-keys/{key_id}/roll", api.AuthMiddleware(http.HandlerFunc(api.RollAPIKey)))
+		// Webhooks
+		router.Handle("POST "+prefix+"/webhooks", api.AuthMiddleware(http.HandlerFunc(api.CreateWebhook)))
+		router.Handle("GET "+prefix+"/webhooks", api.AuthMiddleware(http.HandlerFunc(api.ListWebhooks)))
+		router.Handle("PUT "+prefix+"/webhooks/{webhook_id}", api.AuthMiddleware(http.HandlerFunc(api.UpdateWebhook)))
+		router.Handle("DELETE "+prefix+"/webhooks/{webhook_id}", api.AuthMiddleware(http.HandlerFunc(api.DeleteWebhook)))
+	}
+
+	// Wave API, authenticated by API key (see handlers/routes.go)
+	api.RegisterAPIKeyRoutes(router)
 
 	// Payment page
 	router.Handle("GET /c/{session_id}", http.HandlerFunc(api.PaymentPage))
 	router.Handle("POST /c/{session_id}/succeed", http.HandlerFunc(api.SucceedPayment))
 	router.Handle("POST /c/{session_id}/fail", http.HandlerFunc(api.FailPayment))
+	router.Handle("GET /m/{business_id}", http.HandlerFunc(api.MerchantPage))
+
+	// App payments (scanned QR codes)
+	router.Handle("GET /api/v1/payments/{session_id}", api.AuthMiddleware(http.HandlerFunc(api.GetScannedSession)))
+	router.Handle("POST /api/v1/payments/{session_id}/confirm", api.AuthMiddleware(http.HandlerFunc(api.ConfirmScannedSession)))
+	router.Handle("POST /api/v1/payments/{session_id}/decline", api.AuthMiddleware(http.HandlerFunc(api.DeclineScannedSession)))
+	router.Handle("GET /api/v1/merchants/{business_id}", api.AuthMiddleware(http.HandlerFunc(api.GetScannedMerchant)))
+	router.Handle("POST /api/v1/merchants/{business_id}/pay", api.AuthMiddleware(http.HandlerFunc(api.PayMerchant)))
 
 	server := &http.Server{
 		Addr:         ":" + cmp.Or(os.Getenv("PORT"), "8080"),
@@ -137,4 +161,5 @@ func main() {
 	} else {
 		slog.Info("Server gracefully stopped")
 	}
+	api.Close()
 }